@@ -7,7 +7,10 @@ import (
 	acli "github.com/arduino/arduino-cli/cli"
 	aconfig "github.com/arduino/arduino-cli/configuration"
 	"github.com/arduino/arduino-cli/i18n"
+	"github.com/ksrichard/apm/cache"
 	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/resolver"
+	"github.com/ksrichard/apm/util"
 	"github.com/phayes/freeport"
 	"google.golang.org/grpc"
 	"io"
@@ -18,6 +21,7 @@ import (
 	"github.com/spf13/cobra"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -35,15 +39,27 @@ func RunCmdInteractive(cmd *cobra.Command, command []string) error {
 }
 
 type ArduinoCli struct {
+	// if set, libraries and cores are installed into this directory instead of the global arduino-cli directories
+	IsolationDir string
+	// use only the local package cache, nothing is downloaded
+	Offline        bool
 	grpcServerPort int
 	cmd            *cobra.Command
 	client         rpc.ArduinoCoreServiceClient
 	grpcConn       *grpc.ClientConn
 	grpcInstance   *rpc.Instance
+	// library index lookups by lower case library name
+	libraries map[string]*rpc.SearchedLibrary
 }
 
 func (c *ArduinoCli) Init() error {
 	c.cmd = c.getArduinoCliCommand()
+	if c.Offline {
+		err := c.restoreIndexes()
+		if err != nil {
+			return err
+		}
+	}
 	grpcPort, err := c.startCliGrpcServer()
 	if err != nil {
 		return err
@@ -83,6 +99,10 @@ func (c *ArduinoCli) startCliGrpcServer() (int, error) {
 
 func (c *ArduinoCli) getArduinoCliCommand() *cobra.Command {
 	aconfig.Settings = aconfig.Init(aconfig.FindConfigFileInArgsOrWorkingDirectory(os.Args))
+	if c.IsolationDir != "" {
+		aconfig.Settings.Set("directories.Data", filepath.Join(c.IsolationDir, "data"))
+		aconfig.Settings.Set("directories.User", filepath.Join(c.IsolationDir, "user"))
+	}
 	i18n.Init()
 	return acli.NewCommand()
 }
@@ -132,132 +152,300 @@ func (c *ArduinoCli) getGrpcConnection() (*grpc.ClientConn, error) {
 	return grpc.Dial(fmt.Sprintf("localhost:%d", c.grpcServerPort), grpc.WithInsecure(), grpc.WithBlock())
 }
 
-func (c *ArduinoCli) InstallBoardCore(details *project.ProjectDetails) error {
+// InstallBoardCore installs the additional platforms and the core package of the board with their locked versions
+// (if they still match the project) or with the newest versions matching their version specs, then locks them
+func (c *ArduinoCli) InstallBoardCore(details *project.ProjectDetails, lock *project.ProjectLock) error {
 	log.Println("Installing board...")
 	board := details.Board
 
 	// update board core index
-	additionalArgs := ""
-	if board.BoardManagerUrl != "" {
-		additionalArgs = fmt.Sprintf("--additional-urls %s", board.BoardManagerUrl)
+	err := c.UpdateCoreIndex(board)
+	if err != nil {
+		return err
 	}
-	err := RunCmdInteractive(c.cmd, strings.Split(fmt.Sprintf("core update-index %s", additionalArgs), " "))
+
+	// unknown packages are reported at their position in the project file
+	if !c.Offline {
+		problems, err := c.validateBoard(details, board, details.BoardPath())
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return errors.New(project.FormatProblems(problems))
+		}
+	}
+
+	// additional platforms are installed first, the core of the board may use their tools
+	lockedPlatforms := []project.LockedBoard{}
+	for _, platform := range board.Platforms {
+		log.Printf("Installing platform %s...\n", platform.ID())
+		locked, err := c.installPlatform(board, platform.Package, platform.Architecture, platform.Version, lock.LockedPlatform(platform))
+		if err != nil {
+			return err
+		}
+		lockedPlatforms = append(lockedPlatforms, *locked)
+	}
+
+	// prefer the locked version if it still matches the project
+	var lockedBoard *project.LockedBoard
+	if lock.BoardLocked(board) {
+		lockedBoard = lock.Board
+	}
+	lockedBoard, err = c.installPlatform(board, board.Package, board.Architecture, board.Version, lockedBoard)
 	if err != nil {
 		return err
 	}
+	lock.Board = lockedBoard
+	lock.Platforms = lockedPlatforms
+	if len(lock.Platforms) == 0 {
+		lock.Platforms = nil
+	}
+	return nil
+}
 
-	if strings.ToLower(board.Version) == "latest" {
-		return RunCmdInteractive(c.cmd, strings.Split(fmt.Sprintf("core install %s:%s %s", board.Package, board.Architecture, additionalArgs), " "))
+// installPlatform installs the locked version of a platform (or the newest version matching its version spec if it is
+// not locked), then verifies and returns the installed version
+func (c *ArduinoCli) installPlatform(board *project.ProjectBoard, pkg string, arch string, spec string, locked *project.LockedBoard) (*project.LockedBoard, error) {
+	version := spec
+	var err error
+	if locked != nil {
+		version = locked.Version
+	} else if !project.IsLatest(version) {
+		version, err = c.resolvePlatformVersion(pkg, arch, spec)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return RunCmdInteractive(c.cmd, strings.Split(fmt.Sprintf("core install %s:%s@%s %s", board.Package, board.Architecture, board.Version, additionalArgs), " "))
+	if c.Offline {
+		if locked == nil {
+			return nil, errors.New(fmt.Sprintf("board core %s:%s is not locked, it can not be installed offline", pkg, arch))
+		}
+		err = c.restoreFromCache(cache.PlatformKey(pkg, arch, version), downloadsDir())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	platform := fmt.Sprintf("%s:%s", pkg, arch)
+	if !project.IsLatest(version) {
+		platform = fmt.Sprintf("%s@%s", platform, version)
+	}
+	err = RunCmdInteractive(c.cmd, append([]string{"core", "install", platform}, additionalUrlsArgs(board)...))
+	if err != nil {
+		return nil, err
+	}
+
+	// verify the installed version
+	installedVersion, err := c.InstalledPlatformVersion(pkg, arch)
+	if err != nil {
+		return nil, err
+	}
+	if installedVersion == "" {
+		return nil, errors.New(fmt.Sprintf("board core %s:%s is not installed", pkg, arch))
+	}
+	if (!project.IsLatest(version) && installedVersion != version) || (!project.IsLatest(spec) && !project.VersionSatisfies(spec, installedVersion)) {
+		return nil, errors.New(fmt.Sprintf("board core %s:%s@%s is installed instead of %s", pkg, arch, installedVersion, version))
+	}
+	return &project.LockedBoard{
+		Package:      pkg,
+		Architecture: arch,
+		Version:      installedVersion,
+	}, nil
 }
 
-func (c *ArduinoCli) CheckDependencyVersionMismatch(dep project.ProjectDependency, details *project.ProjectDetails) error {
-	for _, projectLib := range details.Dependencies {
-		if projectLib.Library != dep.Library || (projectLib.Library == dep.Library && projectLib.Version != dep.Version) {
-			libs, err := c.SearchLibrary(projectLib.Library)
-			if err != nil {
-				return err
-			}
-			for _, lib := range libs {
-				if lib.Name == projectLib.Library {
-					if strings.ToLower(projectLib.Version) == "latest" {
-						projectLib.Version = lib.Latest.Version
-					}
-					for _, release := range lib.Releases {
-						if release.Version == projectLib.Version {
-							// check for dep mismatch directly
-							if lib.Name == dep.Library && release.Version != dep.Version {
-								return errors.New(
-									fmt.Sprintf(
-										"Version mismatch: %s@%s ->|<- %s@%s", dep.Library, dep.Version, lib.Name, release.Version,
-									),
-								)
-							}
-
-							// check for dep mismatch in found lib dependencies
-							for _, dependency := range release.Dependencies {
-								if dependency.VersionConstraint == "" {
-									dependency.VersionConstraint = "latest"
-								}
-								if dependency.Name == dep.Library && dependency.VersionConstraint != dep.Version {
-									return errors.New(
-										fmt.Sprintf(
-											"Version mismatch: %s@%s ->|<- %s -> %s@%s", dep.Library, dep.Version, projectLib.Library, dependency.Name, dependency.VersionConstraint,
-										),
-									)
-								}
-							}
-						}
-					}
-				}
-			}
+// ResolveDependencies chooses the version of every library needed by the project (including indirect dependencies),
+// locked versions are preferred if they are still valid
+func (c *ArduinoCli) ResolveDependencies(details *project.ProjectDetails, lock *project.ProjectLock) ([]resolver.Resolved, error) {
+	var requirements []resolver.Requirement
+	for _, dep := range details.Dependencies {
+		if dep.Library == "" {
+			continue
+		}
+		if dep.Version == "" {
+			return nil, errors.New(fmt.Sprintf("please specify a version for '%s'", dep.Library))
 		}
+		requirements = append(requirements, resolver.Requirement{Library: dep.Library, Version: dep.Version})
 	}
-	return nil
+
+	preferred := make(map[string]string)
+	for _, locked := range lock.Dependencies {
+		if locked.Library != "" {
+			preferred[locked.Library] = locked.Version
+		}
+	}
+
+	r := &resolver.Resolver{
+		Index:     c.libraryReleases,
+		Root:      details.Name(),
+		Preferred: preferred,
+	}
+	return r.Resolve(requirements)
 }
 
-func (c *ArduinoCli) InstallDependencies(details *project.ProjectDetails) error {
+// libraryReleases returns all releases of a library from the library index
+func (c *ArduinoCli) libraryReleases(name string) ([]*resolver.Release, error) {
+	lib, err := c.lookupLibrary(name)
+	if err != nil || lib == nil {
+		return nil, err
+	}
+	var releases []*resolver.Release
+	for _, release := range lib.Releases {
+		r := &resolver.Release{Name: lib.Name, Version: release.Version}
+		for _, dep := range release.Dependencies {
+			r.Dependencies = append(r.Dependencies, resolver.Dependency{Name: dep.Name, Constraint: dep.VersionConstraint})
+		}
+		releases = append(releases, r)
+	}
+	return releases, nil
+}
+
+func (c *ArduinoCli) InstallDependencies(details *project.ProjectDetails, lock *project.ProjectLock) error {
 	log.Println("Installing dependencies...")
 
+	// install vendored libraries if they still match the project
+	if details.Vendor != "" {
+		problems, err := CheckVendoredDependencies(details, lock)
+		if err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			return c.installVendoredDependencies(details, lock)
+		}
+		for _, problem := range problems {
+			log.Printf("WARNING: %s\n", problem)
+		}
+		log.Println("WARNING: vendored libraries do not match the project, installing them from their sources...")
+	}
+
 	// update library index
-	err := RunCmdInteractive(c.cmd, strings.Split("lib update-index", " "))
+	err := c.UpdateLibraryIndex()
 	if err != nil {
 		return err
 	}
 
-	// check if we have any dependency mismatch with current libs
+	// verify zip files before installing anything
 	for _, dep := range details.Dependencies {
-		err = c.CheckDependencyVersionMismatch(dep, details)
+		err = VerifyZipFile(dep)
+		if err != nil {
+			return err
+		}
+	}
+
+	// install exactly what is locked if the lock still matches the project
+	if lockedDeps, ok := lock.LockedDependencies(details); ok {
+		log.Printf("Installing dependencies from %s...\n", details.LockFileName())
+		err = c.installLockedDependencies(details, lockedDeps, lock)
+		if err != nil {
+			return err
+		}
+		return c.hashInstalledDependencies(lock)
+	}
+
+	if c.Offline {
+		return errors.New(fmt.Sprintf("%s does not match %s, the dependencies can not be resolved offline", details.LockFileName(), details.FileName()))
+	}
+
+	// resolve the versions of all libraries
+	resolved, err := c.ResolveDependencies(details, lock)
+	if err != nil {
+		return err
+	}
+
+	// install all resolved libraries
+	lockedDeps := []project.LockedDependency{}
+	for _, lib := range resolved {
+		err = c.installLibrary(lib.Name, lib.Version, true)
 		if err != nil {
 			return err
 		}
+		lockedDeps = append(lockedDeps, project.LockedDependency{
+			Library:      lib.Name,
+			Version:      lib.Version,
+			Dependencies: lib.Dependencies,
+		})
 	}
 
-	// install all library dependencies
+	// install git and zip dependencies
 	for _, dep := range details.Dependencies {
-		// we have a library specified
 		if dep.Library != "" {
-			// construct library install cmd
-			if dep.Version == "" {
-				return errors.New("please specify a version")
-			}
-			lib := fmt.Sprintf("%s@%s", dep.Library, dep.Version)
-			if strings.ToLower(dep.Version) == "latest" {
-				lib = fmt.Sprintf("%s", dep.Library)
+			continue
+		}
+		if dep.Git != "" && dep.Zip != "" {
+			return errors.New("please specify git or zip, but NOT both")
+		}
+
+		// we have git specified
+		if dep.Git != "" {
+			log.Printf("Installing dependency from GIT repository: %s...\n", dep.Git)
+			commit, err := c.installGitLibrary(dep.Git, dep.Ref, dep.Sha256)
+			if err != nil {
+				return err
 			}
+			lockedDeps = append(lockedDeps, project.LockedDependency{Git: dep.Git, Ref: dep.Ref, Commit: commit})
+		}
 
-			// run lib install
-			err = RunCmdInteractive(c.cmd, []string{"lib", "install", lib})
+		// we have zip specified
+		if dep.Zip != "" {
+			log.Printf("Installing dependency from ZIP file: %s...\n", dep.Zip)
+			hash, err := util.FileSha256(dep.Zip)
 			if err != nil {
 				return err
 			}
-		} else {
-			if dep.Git != "" && dep.Zip != "" {
-				return errors.New("please specify git or zip, but NOT both")
+			err = c.installZipLibrary(dep.Zip)
+			if err != nil {
+				return err
 			}
+			lockedDeps = append(lockedDeps, project.LockedDependency{Zip: dep.Zip, Sha256: hash})
+		}
+	}
+	lock.Dependencies = lockedDeps
+	return c.hashInstalledDependencies(lock)
+}
 
-			// we have git specified
-			if dep.Git != "" {
-				log.Printf("Installing dependency from GIT repository: %s...\n", dep.Git)
-				err = RunCmdInteractive(c.cmd, strings.Split(fmt.Sprintf("lib install --git-url %s", dep.Git), " "))
+func (c *ArduinoCli) installLockedDependencies(details *project.ProjectDetails, lockedDeps []project.LockedDependency, lock *project.ProjectLock) error {
+	for _, locked := range lockedDeps {
+		if locked.Library != "" {
+			if c.Offline {
+				err := c.restoreFromCache(cache.LibraryKey(locked.Library, locked.Version), downloadsDir())
 				if err != nil {
 					return err
 				}
 			}
+			err := c.installLibrary(locked.Library, locked.Version, true)
+			if err != nil {
+				return err
+			}
+		}
 
-			// we have zip specified
-			if dep.Zip != "" {
-				log.Printf("Installing dependency from ZIP file: %s...\n", dep.Zip)
-				err = RunCmdInteractive(c.cmd, strings.Split(fmt.Sprintf("lib install --zip-path %s", dep.Zip), " "))
-				if err != nil {
-					return err
-				}
+		if locked.Git != "" && c.Offline {
+			err := c.restoreFromCache(cache.GitKey(locked.Git, locked.Commit), aconfig.LibrariesDir(aconfig.Settings))
+			if err != nil {
+				return err
+			}
+		} else if locked.Git != "" {
+			log.Printf("Installing dependency from GIT repository: %s@%s...\n", locked.Git, locked.Commit)
+			// the sources must be the same as when they were locked
+			_, err := c.installGitLibrary(locked.Git, locked.Commit, locked.Hash)
+			if err != nil {
+				return err
 			}
 		}
 
+		if locked.Zip != "" {
+			log.Printf("Installing dependency from ZIP file: %s...\n", locked.Zip)
+			// the zip file must be the same as when it was locked, it is locked again by removing and adding it
+			err := VerifyZipFile(project.ProjectDependency{Zip: locked.Zip, Sha256: locked.Sha256})
+			if err != nil {
+				return errors.New(fmt.Sprintf("%s, the zip file has changed since it was locked in %s", err, details.LockFileName()))
+			}
+			err = c.installZipLibrary(locked.Zip)
+			if err != nil {
+				return err
+			}
+		}
 	}
+	lock.Dependencies = lockedDeps
 	return nil
 }
 
@@ -273,3 +461,208 @@ func (c *ArduinoCli) UninstallDependency(dep *project.ProjectDependency) error {
 	log.Printf("Uninstalling dependency '%s'...\n", depName)
 	return RunCmdInteractive(c.cmd, []string{"lib", "uninstall", depName})
 }
+
+func (c *ArduinoCli) UpdateLibraryIndex() error {
+	if c.Offline {
+		return nil
+	}
+	err := RunCmdInteractive(c.cmd, strings.Split("lib update-index", " "))
+	if err != nil {
+		return err
+	}
+	return c.rescan()
+}
+
+func (c *ArduinoCli) UpdateCoreIndex(board *project.ProjectBoard) error {
+	if c.Offline {
+		return nil
+	}
+	err := RunCmdInteractive(c.cmd, append([]string{"core", "update-index"}, additionalUrlsArgs(board)...))
+	if err != nil {
+		return err
+	}
+	return c.rescan()
+}
+
+// additionalUrlsArgs returns the arguments of the board manager URLs of the board
+func additionalUrlsArgs(board *project.ProjectBoard) []string {
+	urls := board.IndexUrls()
+	if len(urls) == 0 {
+		return nil
+	}
+	return []string{"--additional-urls", strings.Join(urls, ",")}
+}
+
+// rescan reloads the indexes and installed libraries/platforms of the grpc instance
+func (c *ArduinoCli) rescan() error {
+	c.libraries = nil
+	_, err := c.client.Rescan(context.Background(), &rpc.RescanRequest{Instance: c.grpcInstance})
+	return err
+}
+
+func (c *ArduinoCli) installLibrary(name string, version string, noDeps bool) error {
+	lib := name
+	if version != "" {
+		lib = fmt.Sprintf("%s@%s", name, version)
+	}
+	log.Printf("Installing %s...\n", lib)
+	stream, err := c.client.LibraryInstall(context.Background(), &rpc.LibraryInstallRequest{
+		Instance: c.grpcInstance,
+		Name:     name,
+		Version:  version,
+		NoDeps:   noDeps,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("failed to install %s: %s", lib, err))
+		}
+		logTaskProgress(resp.TaskProgress)
+	}
+}
+
+func (c *ArduinoCli) installZipLibrary(zipFile string) error {
+	stream, err := c.client.ZipLibraryInstall(context.Background(), &rpc.ZipLibraryInstallRequest{
+		Instance:  c.grpcInstance,
+		Path:      zipFile,
+		Overwrite: true,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return c.rescan()
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("failed to install %s: %s", zipFile, err))
+		}
+		logTaskProgress(resp.TaskProgress)
+	}
+}
+
+// lookupLibrary returns a library from the library index by its exact name or nil if it does not exist
+func (c *ArduinoCli) lookupLibrary(name string) (*rpc.SearchedLibrary, error) {
+	key := strings.ToLower(name)
+	if lib, ok := c.libraries[key]; ok {
+		return lib, nil
+	}
+	libs, err := c.SearchLibrary(name)
+	if err != nil {
+		return nil, err
+	}
+	var result *rpc.SearchedLibrary
+	for _, lib := range libs {
+		if strings.ToLower(lib.Name) == key {
+			result = lib
+		}
+	}
+	if c.libraries == nil {
+		c.libraries = make(map[string]*rpc.SearchedLibrary)
+	}
+	c.libraries[key] = result
+	return result, nil
+}
+
+// FindLibrary returns a library from the library index by its exact name
+func (c *ArduinoCli) FindLibrary(name string) (*rpc.SearchedLibrary, error) {
+	lib, err := c.lookupLibrary(name)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return nil, errors.New(fmt.Sprintf("library '%s' not found in library index", name))
+	}
+	return lib, nil
+}
+
+// PlatformVersions returns all available versions of a board core package from the package indexes
+func (c *ArduinoCli) PlatformVersions(pkg string, arch string) ([]string, error) {
+	err := c.rescan()
+	if err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("%s:%s", pkg, arch)
+	response, err := c.client.PlatformSearch(context.Background(), &rpc.PlatformSearchRequest{
+		Instance:    c.grpcInstance,
+		SearchArgs:  id,
+		AllVersions: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, platform := range response.SearchOutput {
+		if platform.Id == id {
+			versions = append(versions, platform.Latest)
+		}
+	}
+	return versions, nil
+}
+
+// resolvePlatformVersion returns the newest release of a board core package that satisfies the given version
+func (c *ArduinoCli) resolvePlatformVersion(pkg string, arch string, spec string) (string, error) {
+	versions, err := c.PlatformVersions(pkg, arch)
+	if err != nil {
+		return "", err
+	}
+	version := project.MaxSatisfying(spec, versions)
+	if version == "" {
+		return "", errors.New(fmt.Sprintf("no release of board core '%s:%s' matches version '%s'", pkg, arch, spec))
+	}
+	return version, nil
+}
+
+// InstalledPlatformVersion returns the installed version of a board core package or an empty string if it is not installed
+func (c *ArduinoCli) InstalledPlatformVersion(pkg string, arch string) (string, error) {
+	err := c.rescan()
+	if err != nil {
+		return "", err
+	}
+	response, err := c.client.PlatformList(context.Background(), &rpc.PlatformListRequest{Instance: c.grpcInstance})
+	if err != nil {
+		return "", err
+	}
+	for _, platform := range response.InstalledPlatforms {
+		if platform.Id == fmt.Sprintf("%s:%s", pkg, arch) {
+			return platform.Installed, nil
+		}
+	}
+	return "", nil
+}
+
+// InstalledLibraries returns the versions of the libraries installed in the sketchbook by lower case library name
+func (c *ArduinoCli) InstalledLibraries() (map[string]string, error) {
+	err := c.rescan()
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.client.LibraryList(context.Background(), &rpc.LibraryListRequest{Instance: c.grpcInstance})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for _, lib := range response.InstalledLibraries {
+		result[strings.ToLower(lib.Library.Name)] = lib.Library.Version
+	}
+	return result, nil
+}
+
+func logTaskProgress(progress *rpc.TaskProgress) {
+	if progress == nil {
+		return
+	}
+	if progress.Name != "" {
+		log.Println(progress.Name)
+	}
+	if progress.Message != "" {
+		log.Println(progress.Message)
+	}
+}
@@ -0,0 +1,143 @@
+package arduino
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+	"github.com/ksrichard/apm/project"
+)
+
+// BoardOptions returns the menu options of the board from the installed core,
+// the values set in the options of the board are marked as selected
+func (c *ArduinoCli) BoardOptions(board *project.ProjectBoard) ([]*rpc.ConfigOption, error) {
+	fqbn, err := board.BoardFQBN()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.BoardDetails(context.Background(), &rpc.BoardDetailsRequest{
+		Instance: c.grpcInstance,
+		Fqbn:     fqbn,
+	})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("failed to get details of board '%s': %s", fqbn, err))
+	}
+	for key, value := range board.Options {
+		option := findConfigOption(resp.ConfigOptions, key)
+		if option == nil {
+			return nil, errors.New(fmt.Sprintf("board '%s' has no option '%s'", fqbn, key))
+		}
+		found := false
+		for _, optionValue := range option.Values {
+			optionValue.Selected = optionValue.Value == value
+			found = found || optionValue.Selected
+		}
+		if !found {
+			return nil, errors.New(fmt.Sprintf("invalid value '%s' of option '%s' of board '%s'", value, key, fqbn))
+		}
+	}
+	return resp.ConfigOptions, nil
+}
+
+func findConfigOption(options []*rpc.ConfigOption, key string) *rpc.ConfigOption {
+	for _, option := range options {
+		if option.Option == key {
+			return option
+		}
+	}
+	return nil
+}
+
+// ValidateBoards checks that the board core packages of the project and its environments exist in the package indexes
+func (c *ArduinoCli) ValidateBoards(details *project.ProjectDetails) ([]project.Problem, error) {
+	var problems []project.Problem
+	boards := map[string]*project.ProjectBoard{"": details.Board}
+	for name, env := range details.Environments {
+		if env.Board != nil {
+			boards[name] = env.Board
+		}
+	}
+	for name, board := range boards {
+		if board == nil || board.Package == "" || board.Architecture == "" {
+			continue
+		}
+		err := c.UpdateCoreIndex(board)
+		if err != nil {
+			return nil, err
+		}
+		path := []interface{}{"board"}
+		if name != "" {
+			path = []interface{}{"environments", name, "board"}
+		}
+		boardProblems, err := c.validateBoard(details, board, path)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, boardProblems...)
+	}
+	return problems, nil
+}
+
+// validateBoard returns a problem for the board core package and for every additional platform of the board which is
+// not in the (already updated) package indexes, path is the path of the board in the project file
+func (c *ArduinoCli) validateBoard(details *project.ProjectDetails, board *project.ProjectBoard, path []interface{}) ([]project.Problem, error) {
+	var problems []project.Problem
+	platforms := append([]project.ProjectPlatform{{Package: board.Package, Architecture: board.Architecture}}, board.Platforms...)
+	for i, platform := range platforms {
+		versions, err := c.PlatformVersions(platform.Package, platform.Architecture)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) > 0 {
+			continue
+		}
+		message := fmt.Sprintf("unknown board core package '%s'", platform.ID())
+		if len(board.IndexUrls()) == 0 {
+			message += ", 'board_manager_urls' may be missing"
+		}
+		platformPath := append(append([]interface{}{}, path...), "package")
+		if i > 0 {
+			platformPath = append(append([]interface{}{}, path...), "platforms", i-1, "package")
+		}
+		problems = append(problems, details.ProblemAt(message, platformPath...))
+	}
+	return problems, nil
+}
+
+// SearchBoards returns the boards of the installed and of the installable core packages matching the query,
+// the FQBN is only known for boards of installed core packages
+func (c *ArduinoCli) SearchBoards(query string) ([]*rpc.BoardListItem, error) {
+	err := c.rescan()
+	if err != nil {
+		return nil, err
+	}
+	installed, err := c.client.BoardListAll(context.Background(), &rpc.BoardListAllRequest{
+		Instance:   c.grpcInstance,
+		SearchArgs: strings.Fields(query),
+	})
+	if err != nil {
+		return nil, err
+	}
+	installable, err := c.client.BoardSearch(context.Background(), &rpc.BoardSearchRequest{
+		Instance:   c.grpcInstance,
+		SearchArgs: query,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []*rpc.BoardListItem
+	found := make(map[string]bool)
+	for _, board := range append(installed.Boards, installable.Boards...) {
+		if board.Platform == nil {
+			continue
+		}
+		key := board.Platform.Id + "\x00" + board.Name
+		if !found[key] {
+			found[key] = true
+			result = append(result, board)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package arduino
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/ksrichard/apm/project"
+)
+
+// HasMissingDependencies returns true if the board core, any additional platform or any locked library of the project
+// is not installed
+func (c *ArduinoCli) HasMissingDependencies(details *project.ProjectDetails, lock *project.ProjectLock) (bool, error) {
+	board := details.Board
+	if board != nil && board.Package != "" {
+		if !lock.BoardLocked(board) || !lock.PlatformsLocked(board) {
+			return true, nil
+		}
+		platforms := []project.LockedBoard{*lock.Board}
+		for _, platform := range board.Platforms {
+			platforms = append(platforms, *lock.LockedPlatform(platform))
+		}
+		for _, platform := range platforms {
+			installed, err := c.InstalledPlatformVersion(platform.Package, platform.Architecture)
+			if err != nil {
+				return false, err
+			}
+			if installed != platform.Version {
+				return true, nil
+			}
+		}
+	}
+
+	if len(details.Dependencies) == 0 {
+		return false, nil
+	}
+	lockedDeps, ok := lock.LockedDependencies(details)
+	if !ok {
+		return true, nil
+	}
+	installedLibs, err := c.InstalledLibraries()
+	if err != nil {
+		return false, err
+	}
+	installedDirs, err := c.installedLibraryDirs()
+	if err != nil {
+		return false, err
+	}
+	for _, locked := range lockedDeps {
+		if locked.Library != "" {
+			if installedLibs[strings.ToLower(locked.Library)] != locked.Version {
+				return true, nil
+			}
+			continue
+		}
+		dir, err := libraryDir(locked, installedDirs)
+		if err != nil || !dir.IsDir() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Compile builds the sketch of the project and exports the binaries into the output directory of the project.
+// The output of the compiler is written to stdout and stderr.
+func (c *ArduinoCli) Compile(details *project.ProjectDetails, clean bool) error {
+	if details.Board == nil {
+		return errors.New(fmt.Sprintf("no board is set in '%s'", details.FileName()))
+	}
+	fqbn, err := details.Board.FQBN()
+	if err != nil {
+		return err
+	}
+	sketchPath, err := filepath.Abs(details.Dir)
+	if err != nil {
+		return err
+	}
+	outputDir := paths.New(details.OutputDir())
+	err = outputDir.MkdirAll()
+	if err != nil {
+		return err
+	}
+	outputDir, err = outputDir.Abs()
+	if err != nil {
+		return err
+	}
+
+	request := &rpc.CompileRequest{
+		Instance:   c.grpcInstance,
+		Fqbn:       fqbn,
+		SketchPath: sketchPath,
+		ExportDir:  outputDir.String(),
+		Clean:      clean,
+		// build properties and defines of the board
+		BuildProperties: details.Board.CompileProperties(),
+	}
+	if options := details.Board.Build; options != nil {
+		request.Warnings = options.Warnings
+		request.OptimizeForDebug = options.OptimizeForDebug
+		request.Verbose = options.Verbose
+		request.Jobs = options.Jobs
+	}
+
+	log.Printf("Compiling %s for %s...\n", sketchPath, fqbn)
+	stream, err := c.client.Compile(context.Background(), request)
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			log.Printf("Binaries exported to %s\n", outputDir)
+			return nil
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("compilation failed: %s", err))
+		}
+		os.Stdout.Write(resp.OutStream)
+		os.Stderr.Write(resp.ErrStream)
+	}
+}
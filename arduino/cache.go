@@ -0,0 +1,265 @@
+package arduino
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/arduino/arduino-cli/arduino/cores/packagemanager"
+	aconfig "github.com/arduino/arduino-cli/configuration"
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/ksrichard/apm/cache"
+	"github.com/ksrichard/apm/project"
+	semver "go.bug.st/relaxed-semver"
+)
+
+func downloadsDir() *paths.Path {
+	return paths.New(aconfig.Settings.GetString("directories.Downloads"))
+}
+
+func dataDir() *paths.Path {
+	return paths.New(aconfig.Settings.GetString("directories.Data"))
+}
+
+// isIndexFile returns true if the file in the data directory is a library or package index
+func isIndexFile(name string) bool {
+	return strings.HasSuffix(name, "index.json") || strings.HasSuffix(name, "index.json.sig")
+}
+
+// requiredIndexFiles returns the index files needed to install the project
+func requiredIndexFiles(details *project.ProjectDetails) []string {
+	var result []string
+	if details.Board != nil && details.Board.Package != "" {
+		result = append(result, "package_index.json")
+		for _, indexUrl := range details.Board.IndexUrls() {
+			if parsed, err := url.Parse(indexUrl); err == nil {
+				result = append(result, path.Base(parsed.Path))
+			}
+		}
+	}
+	if len(details.Dependencies) > 0 {
+		result = append(result, "library_index.json")
+	}
+	return result
+}
+
+// libraryArchive returns the path of the archive of a library release relative to the downloads directory
+func (c *ArduinoCli) libraryArchive(name string, version string) (string, error) {
+	lib, err := c.FindLibrary(name)
+	if err != nil {
+		return "", err
+	}
+	release, ok := lib.Releases[version]
+	if !ok || release.Resources == nil {
+		return "", errors.New(fmt.Sprintf("failed to find '%s@%s' in the library index", name, version))
+	}
+	return filepath.Join(release.Resources.CachePath, release.Resources.ArchiveFilename), nil
+}
+
+// platformArchives returns the paths of the archives of a platform release and its tools relative to the downloads directory
+func platformArchives(pkg string, arch string, version string) ([]string, error) {
+	parsedVersion, err := semver.Parse(version)
+	if err != nil {
+		return nil, err
+	}
+	pm := packagemanager.NewPackageManager(dataDir(), aconfig.PackagesDir(aconfig.Settings), downloadsDir(), dataDir().Join("tmp"))
+	files, err := ioutil.ReadDir(dataDir().String())
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), "package_") && strings.HasSuffix(file.Name(), "index.json") {
+			_, err = pm.LoadPackageIndexFromFile(dataDir().Join(file.Name()))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	release, tools, err := pm.FindPlatformReleaseDependencies(&packagemanager.PlatformReference{
+		Package:              pkg,
+		PlatformArchitecture: arch,
+		PlatformVersion:      parsedVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := []string{filepath.Join(release.Resource.CachePath, release.Resource.ArchiveFileName)}
+	for _, tool := range tools {
+		if resource := tool.GetCompatibleFlavour(); resource != nil {
+			result = append(result, filepath.Join(resource.CachePath, resource.ArchiveFileName))
+		}
+	}
+	return result, nil
+}
+
+// UpdateCache stores the index files and everything installed for the project in the local package cache
+func (c *ArduinoCli) UpdateCache(details *project.ProjectDetails, lock *project.ProjectLock) error {
+	packageCache, err := cache.Open()
+	if err != nil {
+		return err
+	}
+
+	// index files
+	files, err := ioutil.ReadDir(dataDir().String())
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !file.IsDir() && isIndexFile(file.Name()) {
+			err = packageCache.Add(cache.IndexKey(file.Name()), dataDir(), []string{file.Name()})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// board core, additional platforms and tools, archives which are not in the downloads directory (anymore) can not be cached
+	var platforms []project.LockedBoard
+	if lock.Board != nil {
+		platforms = append(platforms, *lock.Board)
+	}
+	for _, platform := range append(platforms, lock.Platforms...) {
+		key := cache.PlatformKey(platform.Package, platform.Architecture, platform.Version)
+		archives, err := platformArchives(platform.Package, platform.Architecture, platform.Version)
+		if err != nil {
+			return err
+		}
+		if !packageCache.Has(key) && allExist(downloadsDir(), archives) {
+			err = packageCache.Add(key, downloadsDir(), archives)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// libraries
+	for _, locked := range lock.Dependencies {
+		if locked.Library != "" {
+			key := cache.LibraryKey(locked.Library, locked.Version)
+			archive, err := c.libraryArchive(locked.Library, locked.Version)
+			if err != nil {
+				return err
+			}
+			if !packageCache.Has(key) && allExist(downloadsDir(), []string{archive}) {
+				err = packageCache.Add(key, downloadsDir(), []string{archive})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if locked.Git != "" && locked.Dir != "" {
+			key := cache.GitKey(locked.Git, locked.Commit)
+			libDir := aconfig.LibrariesDir(aconfig.Settings).Join(locked.Dir)
+			if !packageCache.Has(key) && libDir.IsDir() {
+				err = packageCache.AddDir(key, libDir)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	packageCache.RegisterProject(details.Dir)
+	return packageCache.Save()
+}
+
+func allExist(dir *paths.Path, files []string) bool {
+	for _, file := range files {
+		if !dir.Join(file).Exist() {
+			return false
+		}
+	}
+	return true
+}
+
+// MissingFromCache returns everything needed to install the project which is not in the local package cache
+func MissingFromCache(details *project.ProjectDetails, lock *project.ProjectLock) ([]string, error) {
+	packageCache, err := cache.Open()
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, file := range requiredIndexFiles(details) {
+		if !packageCache.Has(cache.IndexKey(file)) {
+			missing = append(missing, fmt.Sprintf("index file %s", file))
+		}
+	}
+
+	// board core
+	board := details.Board
+	if board != nil && board.Package != "" {
+		if !lock.BoardLocked(board) {
+			missing = append(missing, fmt.Sprintf("board core %s:%s is not locked in %s", board.Package, board.Architecture, details.LockFileName()))
+		} else if !packageCache.Has(cache.PlatformKey(lock.Board.Package, lock.Board.Architecture, lock.Board.Version)) {
+			missing = append(missing, fmt.Sprintf("board core %s:%s@%s", lock.Board.Package, lock.Board.Architecture, lock.Board.Version))
+		}
+		for _, platform := range board.Platforms {
+			locked := lock.LockedPlatform(platform)
+			if locked == nil {
+				missing = append(missing, fmt.Sprintf("platform %s is not locked in %s", platform.ID(), details.LockFileName()))
+			} else if !packageCache.Has(cache.PlatformKey(locked.Package, locked.Architecture, locked.Version)) {
+				missing = append(missing, fmt.Sprintf("platform %s@%s", platform.ID(), locked.Version))
+			}
+		}
+	}
+
+	// libraries, vendored libraries are installed without the cache
+	if len(details.Dependencies) == 0 {
+		return missing, nil
+	}
+	if details.Vendor != "" {
+		problems, err := CheckVendoredDependencies(details, lock)
+		if err != nil {
+			return nil, err
+		}
+		if len(problems) == 0 {
+			return missing, nil
+		}
+	}
+	lockedDeps, ok := lock.LockedDependencies(details)
+	if !ok {
+		return append(missing, fmt.Sprintf("%s does not match %s, the dependencies can not be resolved offline", details.LockFileName(), details.FileName())), nil
+	}
+	for _, locked := range lockedDeps {
+		if locked.Library != "" && !packageCache.Has(cache.LibraryKey(locked.Library, locked.Version)) {
+			missing = append(missing, fmt.Sprintf("library %s@%s", locked.Library, locked.Version))
+		}
+		if locked.Git != "" && !packageCache.Has(cache.GitKey(locked.Git, locked.Commit)) {
+			missing = append(missing, fmt.Sprintf("git repository %s@%s", locked.Git, locked.Commit))
+		}
+		if locked.Zip != "" && !paths.New(locked.Zip).Exist() {
+			missing = append(missing, fmt.Sprintf("zip file %s", locked.Zip))
+		}
+	}
+	return missing, nil
+}
+
+// restoreIndexes copies the cached index files into the data directory
+func (c *ArduinoCli) restoreIndexes() error {
+	packageCache, err := cache.Open()
+	if err != nil {
+		return err
+	}
+	for _, key := range packageCache.IndexKeys() {
+		err = packageCache.Restore(key, dataDir())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreFromCache copies a cached archive into the downloads directory or cached git sources into the libraries directory
+func (c *ArduinoCli) restoreFromCache(key string, dir *paths.Path) error {
+	packageCache, err := cache.Open()
+	if err != nil {
+		return err
+	}
+	log.Printf("Restoring %s from cache...\n", key)
+	return packageCache.Restore(key, dir)
+}
@@ -0,0 +1,164 @@
+package arduino
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	aconfig "github.com/arduino/arduino-cli/configuration"
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/util"
+)
+
+// VerifyZipFile checks the sha256 of a zip dependency if it is set in apm.json
+func VerifyZipFile(dep project.ProjectDependency) error {
+	if dep.Zip == "" || dep.Sha256 == "" {
+		return nil
+	}
+	hash, err := util.FileSha256(dep.Zip)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(hash, dep.Sha256) {
+		return errors.New(fmt.Sprintf("checksum mismatch for '%s': expected sha256 %s, got %s", dep.Zip, dep.Sha256, hash))
+	}
+	return nil
+}
+
+// zipLibraryName returns the name of the library folder in a library zip file
+func zipLibraryName(zipFile string) (string, error) {
+	reader, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	for _, file := range reader.File {
+		name := strings.SplitN(strings.TrimPrefix(file.Name, "/"), "/", 2)[0]
+		if name != "" && name != "__MACOSX" {
+			return name, nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf("'%s' does not contain a library", zipFile))
+}
+
+// installedLibraryDirs returns the directories of the libraries installed in the sketchbook by lower case library name
+func (c *ArduinoCli) installedLibraryDirs() (map[string]string, error) {
+	err := c.rescan()
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.client.LibraryList(context.Background(), &rpc.LibraryListRequest{Instance: c.grpcInstance})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for _, lib := range response.InstalledLibraries {
+		if lib.Library.Location == rpc.LibraryLocation_LIBRARY_LOCATION_USER {
+			result[strings.ToLower(lib.Library.Name)] = lib.Library.InstallDir
+		}
+	}
+	return result, nil
+}
+
+// libraryDir returns the directory where a locked dependency is installed
+func libraryDir(locked project.LockedDependency, installedDirs map[string]string) (*paths.Path, error) {
+	if locked.Library != "" {
+		if dir, ok := installedDirs[strings.ToLower(locked.Library)]; ok {
+			return paths.New(dir), nil
+		}
+		return nil, errors.New(fmt.Sprintf("'%s' is not installed", locked.Library))
+	}
+	libsDir := aconfig.LibrariesDir(aconfig.Settings)
+	if libsDir == nil {
+		return nil, errors.New("user directory not set")
+	}
+	if locked.Git != "" {
+		return libsDir.Join(gitLibraryName(locked.Git)), nil
+	}
+	name, err := zipLibraryName(locked.Zip)
+	if err != nil {
+		return nil, err
+	}
+	return libsDir.Join(name), nil
+}
+
+// hashInstalledDependencies records the directory name and the sha256 of the newly installed libraries in the lock,
+// hashes which are already recorded are kept so local changes can be detected by VerifyInstalledDependencies
+func (c *ArduinoCli) hashInstalledDependencies(lock *project.ProjectLock) error {
+	installedDirs, err := c.installedLibraryDirs()
+	if err != nil {
+		return err
+	}
+	for i, locked := range lock.Dependencies {
+		if locked.Hash != "" && locked.Dir != "" {
+			continue
+		}
+		dir, err := libraryDir(locked, installedDirs)
+		if err != nil {
+			return err
+		}
+		lock.Dependencies[i].Dir = dir.Base()
+		if locked.Hash != "" {
+			continue
+		}
+		hash, err := util.DirSha256(dir.String())
+		if err != nil {
+			return err
+		}
+		lock.Dependencies[i].Hash = hash
+	}
+	return nil
+}
+
+// LibraryVerification is the result of comparing an installed library directory with its locked hash
+type LibraryVerification struct {
+	Name   string
+	Dir    string
+	Status string
+}
+
+const (
+	VerificationOk       = "ok"
+	VerificationModified = "modified"
+	VerificationMissing  = "missing"
+	VerificationNoHash   = "no hash"
+)
+
+// VerifyInstalledDependencies re-hashes every installed library directory of the lock
+func (c *ArduinoCli) VerifyInstalledDependencies(lock *project.ProjectLock) ([]LibraryVerification, error) {
+	installedDirs, err := c.installedLibraryDirs()
+	if err != nil {
+		return nil, err
+	}
+	var result []LibraryVerification
+	for _, locked := range lock.Dependencies {
+		verification := LibraryVerification{Name: lockedName(locked)}
+
+		dir, err := libraryDir(locked, installedDirs)
+		if err != nil || !dir.IsDir() {
+			verification.Status = VerificationMissing
+			result = append(result, verification)
+			continue
+		}
+		verification.Dir = dir.String()
+		if locked.Hash == "" {
+			verification.Status = VerificationNoHash
+			result = append(result, verification)
+			continue
+		}
+		hash, err := util.DirSha256(dir.String())
+		if err != nil {
+			return nil, err
+		}
+		verification.Status = VerificationOk
+		if hash != locked.Hash {
+			verification.Status = VerificationModified
+		}
+		result = append(result, verification)
+	}
+	return result, nil
+}
@@ -0,0 +1,172 @@
+package arduino
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	aconfig "github.com/arduino/arduino-cli/configuration"
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/ksrichard/apm/util"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+// abbreviatedCommitRegex matches abbreviated commit hashes (e.g. b88d13c)
+var abbreviatedCommitRegex = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+
+// gitLibraryName returns the name of the library folder for a git repository URL
+func gitLibraryName(gitUrl string) string {
+	name := gitUrl
+	if parsed, err := url.Parse(gitUrl); err == nil && parsed.Path != "" && !strings.HasPrefix(gitUrl, "git@") {
+		name = parsed.Path
+	}
+	name = strings.TrimRight(name, "/")
+	name = name[strings.LastIndexAny(name, "/:")+1:]
+	return strings.TrimSuffix(name, ".git")
+}
+
+// installGitLibrary clones the repository, checks out the given revision (HEAD if empty), verifies the sha256
+// of the sources (if set) and installs it into the user libraries directory. The installed commit is returned.
+func (c *ArduinoCli) installGitLibrary(gitUrl string, revision string, sha256 string) (string, error) {
+	libsDir := aconfig.LibrariesDir(aconfig.Settings)
+	if libsDir == nil {
+		return "", errors.New("user directory not set")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "apm-git-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	libName := gitLibraryName(gitUrl)
+	clonePath := filepath.Join(tmpDir, libName)
+	repo, err := git.PlainClone(clonePath, false, &git.CloneOptions{
+		URL:      gitUrl,
+		Progress: os.Stdout,
+	})
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("failed to clone '%s': %s", gitUrl, err))
+	}
+
+	if revision == "" {
+		revision = "HEAD"
+	}
+	hash, err := resolveGitRevision(repo, revision)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("failed to find '%s' in '%s': %s", revision, gitUrl, err))
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true})
+	if err != nil {
+		return "", err
+	}
+
+	// we don't want the installed library to be a git repository
+	err = os.RemoveAll(filepath.Join(clonePath, ".git"))
+	if err != nil {
+		return "", err
+	}
+
+	if sha256 != "" {
+		hash, err := util.DirSha256(clonePath)
+		if err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(hash, sha256) {
+			return "", errors.New(fmt.Sprintf("checksum mismatch for '%s': expected sha256 %s, got %s", gitUrl, sha256, hash))
+		}
+	}
+
+	installPath := libsDir.Join(libName)
+	if err := libsDir.MkdirAll(); err != nil {
+		return "", err
+	}
+	if err := installPath.RemoveAll(); err != nil {
+		return "", err
+	}
+	log.Printf("Installing %s@%s to %s...\n", libName, hash.String(), installPath)
+	err = paths.New(clonePath).CopyDirTo(installPath)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// resolveGitRevision returns the commit of a revision of a cloned repository: a tag, a branch (only the default branch
+// is checked out by the clone, the other ones are remote branches), a commit or an abbreviated commit
+func resolveGitRevision(repo *git.Repository, revision string) (*plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err == nil {
+		return hash, nil
+	}
+	if remoteHash, remoteErr := repo.ResolveRevision(plumbing.Revision("refs/remotes/origin/" + revision)); remoteErr == nil {
+		return remoteHash, nil
+	}
+	if !abbreviatedCommitRegex.MatchString(revision) {
+		return nil, err
+	}
+	commits, commitsErr := repo.CommitObjects()
+	if commitsErr != nil {
+		return nil, commitsErr
+	}
+	var found *plumbing.Hash
+	prefix := strings.ToLower(revision)
+	commitsErr = commits.ForEach(func(commit *object.Commit) error {
+		if !strings.HasPrefix(commit.Hash.String(), prefix) {
+			return nil
+		}
+		if found != nil && *found != commit.Hash {
+			return errors.New(fmt.Sprintf("abbreviated commit '%s' is ambiguous", revision))
+		}
+		commitHash := commit.Hash
+		found = &commitHash
+		return nil
+	})
+	if commitsErr != nil {
+		return nil, commitsErr
+	}
+	if found == nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// GitTags returns the tags of a remote git repository with the commits they point to
+func (c *ArduinoCli) GitTags(gitUrl string) (map[string]string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{gitUrl},
+	})
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("failed to list tags of '%s': %s", gitUrl, err))
+	}
+	result := make(map[string]string)
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		// annotated tags are listed twice, the peeled one (ending with ^{}) points to the commit
+		tag := ref.Name().Short()
+		if strings.HasSuffix(tag, "^{}") {
+			result[strings.TrimSuffix(tag, "^{}")] = ref.Hash().String()
+		} else if _, ok := result[tag]; !ok {
+			result[tag] = ref.Hash().String()
+		}
+	}
+	return result, nil
+}
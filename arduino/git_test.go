@@ -0,0 +1,105 @@
+package arduino
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// commitFile writes a file into the worktree of a repository and commits it
+func commitFile(t *testing.T, repo *git.Repository, dir string, name string) plumbing.Hash {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = worktree.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := worktree.Commit(name, &git.CommitOptions{
+		Author: &object.Signature{Name: "apm", Email: "apm@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestResolveGitRevision(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "apm-git-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// origin repository with a tag on the default branch and a second branch
+	originDir := filepath.Join(tmpDir, "origin")
+	origin, err := git.PlainInit(originDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainCommit := commitFile(t, origin, originDir, "main.txt")
+	if _, err = origin.CreateTag("v1.0", mainCommit, nil); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := origin.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("dev"), Create: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	devCommit := commitFile(t, origin, originDir, "dev.txt")
+	err = worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.Master})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := git.PlainClone(filepath.Join(tmpDir, "clone"), false, &git.CloneOptions{URL: originDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tags are fetched by the clone
+	if err = repo.Fetch(&git.FetchOptions{RefSpecs: []config.RefSpec{"refs/tags/*:refs/tags/*"}}); err != nil && err != git.NoErrAlreadyUpToDate {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		revision string
+		expected plumbing.Hash
+	}{
+		{"HEAD", mainCommit},
+		{"master", mainCommit},
+		{"v1.0", mainCommit},
+		{"dev", devCommit},
+		{devCommit.String(), devCommit},
+		{devCommit.String()[:7], devCommit},
+	}
+	for _, test := range tests {
+		hash, err := resolveGitRevision(repo, test.revision)
+		if err != nil {
+			t.Errorf("resolveGitRevision(%q) failed: %s", test.revision, err)
+			continue
+		}
+		if *hash != test.expected {
+			t.Errorf("resolveGitRevision(%q) = %s, expected %s", test.revision, hash, test.expected)
+		}
+	}
+
+	for _, revision := range []string{"missing", "0000000"} {
+		if hash, err := resolveGitRevision(repo, revision); err == nil {
+			t.Errorf("resolveGitRevision(%q) = %s, expected an error", revision, hash)
+		}
+	}
+}
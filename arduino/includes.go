@@ -0,0 +1,137 @@
+package arduino
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	aconfig "github.com/arduino/arduino-cli/configuration"
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+)
+
+// IndexLibraryIncludes returns the names of the libraries of the library index by the headers they provide
+// (provides_includes of their releases), headers are lower case
+func (c *ArduinoCli) IndexLibraryIncludes() (map[string][]string, error) {
+	libs, err := c.SearchLibrary("")
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string)
+	for _, lib := range libs {
+		headers := make(map[string]bool)
+		if lib.Latest != nil {
+			for _, header := range lib.Latest.ProvidesIncludes {
+				headers[strings.ToLower(header)] = true
+			}
+		}
+		for _, release := range lib.Releases {
+			for _, header := range release.ProvidesIncludes {
+				headers[strings.ToLower(header)] = true
+			}
+		}
+		for header := range headers {
+			result[header] = append(result[header], lib.Name)
+		}
+	}
+	for header := range result {
+		sort.Strings(result[header])
+	}
+	return result, nil
+}
+
+// InstalledLibraryIncludes returns the names of the libraries installed in the sketchbook by the headers they provide,
+// headers are lower case
+func (c *ArduinoCli) InstalledLibraryIncludes() (map[string][]string, error) {
+	libs, err := c.listLibraries()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string)
+	for _, lib := range libs {
+		if lib.Location == rpc.LibraryLocation_LIBRARY_LOCATION_USER {
+			for _, header := range lib.ProvidesIncludes {
+				result[strings.ToLower(header)] = append(result[strings.ToLower(header)], lib.Name)
+			}
+		}
+	}
+	for header := range result {
+		sort.Strings(result[header])
+	}
+	return result, nil
+}
+
+// PlatformIncludes returns the headers of an installed board core package (its cores, variants and bundled libraries),
+// headers are lower case
+func (c *ArduinoCli) PlatformIncludes(pkg string, arch string) (map[string]bool, error) {
+	version, err := c.InstalledPlatformVersion(pkg, arch)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		return nil, errors.New(fmt.Sprintf("board core %s:%s is not installed", pkg, arch))
+	}
+	result := make(map[string]bool)
+
+	// headers of the core and of the variants
+	platformDir := aconfig.PackagesDir(aconfig.Settings).Join(pkg, "hardware", arch, version)
+	for _, dir := range []string{"cores", "variants"} {
+		err = filepath.Walk(platformDir.Join(dir).String(), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.IsDir() && isHeaderFile(path) {
+				result[strings.ToLower(info.Name())] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// libraries bundled with the core package (e.g. Wire, SPI)
+	libs, err := c.listLibraries()
+	if err != nil {
+		return nil, err
+	}
+	for _, lib := range libs {
+		if lib.Location == rpc.LibraryLocation_LIBRARY_LOCATION_PLATFORM_BUILTIN && lib.ContainerPlatform == fmt.Sprintf("%s:%s", pkg, arch) {
+			for _, header := range lib.ProvidesIncludes {
+				result[strings.ToLower(header)] = true
+			}
+		}
+	}
+	return result, nil
+}
+
+// listLibraries returns every installed library including the ones bundled with the board core packages
+func (c *ArduinoCli) listLibraries() ([]*rpc.Library, error) {
+	err := c.rescan()
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.client.LibraryList(context.Background(), &rpc.LibraryListRequest{Instance: c.grpcInstance, All: true})
+	if err != nil {
+		return nil, err
+	}
+	var result []*rpc.Library
+	for _, lib := range response.InstalledLibraries {
+		result = append(result, lib.Library)
+	}
+	return result, nil
+}
+
+func isHeaderFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".h", ".hpp", ".hh":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package arduino
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	aconfig "github.com/arduino/arduino-cli/configuration"
+	paths "github.com/arduino/go-paths-helper"
+)
+
+// LibrariesSnapshot keeps the installed libraries as they were before a change, so they can be restored if the change fails
+type LibrariesSnapshot struct {
+	cli     *ArduinoCli
+	libsDir *paths.Path
+	copyDir *paths.Path
+	// names of the entries of the libraries directory when the snapshot was taken
+	existing []string
+	// the libraries directory did not exist when the snapshot was taken
+	missing bool
+}
+
+// SnapshotLibraries keeps every installed library next to the libraries directory, libraries which are not locked
+// (e.g. installed without apm) can be overwritten by a new dependency too. The files are hard linked instead of copied
+// where possible: installing a library replaces its directory, the files of the snapshot are never changed.
+func (c *ArduinoCli) SnapshotLibraries() (*LibrariesSnapshot, error) {
+	libsDir := aconfig.LibrariesDir(aconfig.Settings)
+	if libsDir == nil {
+		return nil, errors.New("user directory not set")
+	}
+	result, err := snapshotLibraries(libsDir)
+	if err != nil {
+		return nil, err
+	}
+	result.cli = c
+	return result, nil
+}
+
+// snapshotLibraries links or copies the entries of a libraries directory into a temporary directory next to it
+func snapshotLibraries(libsDir *paths.Path) (*LibrariesSnapshot, error) {
+	result := &LibrariesSnapshot{libsDir: libsDir}
+	if !libsDir.IsDir() {
+		result.missing = true
+		return result, nil
+	}
+	files, err := ioutil.ReadDir(libsDir.String())
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := libsDir.Parent().MkTempDir(".apm-libraries-")
+	if err != nil {
+		return nil, err
+	}
+	result.copyDir = tmpDir.Join("libraries")
+	err = result.copyDir.MkdirAll()
+	if err != nil {
+		tmpDir.RemoveAll()
+		return nil, err
+	}
+	for _, file := range files {
+		err = linkTree(libsDir.Join(file.Name()).String(), result.copyDir.Join(file.Name()).String())
+		if err != nil {
+			tmpDir.RemoveAll()
+			return nil, err
+		}
+		result.existing = append(result.existing, file.Name())
+	}
+	return result, nil
+}
+
+// linkTree recreates the directories of a tree and hard links its files, files are copied if they can not be linked
+func linkTree(source string, target string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(target, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(dest, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, dest)
+		default:
+			if os.Link(path, dest) == nil {
+				return nil
+			}
+			return paths.New(path).CopyTo(paths.New(dest))
+		}
+	})
+}
+
+// Restore puts back the libraries directory as it was when the snapshot was taken and reloads the installed libraries
+func (s *LibrariesSnapshot) Restore() error {
+	log.Println("Restoring the previously installed libraries...")
+	err := s.restore()
+	if err != nil {
+		return err
+	}
+	return s.cli.rescan()
+}
+
+// restore removes the libraries installed after the snapshot and moves the kept libraries back
+func (s *LibrariesSnapshot) restore() error {
+	if s.missing {
+		return s.libsDir.RemoveAll()
+	}
+	existing := make(map[string]bool)
+	for _, name := range s.existing {
+		existing[name] = true
+	}
+	if s.libsDir.IsDir() {
+		files, err := ioutil.ReadDir(s.libsDir.String())
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if !existing[file.Name()] {
+				err = s.libsDir.Join(file.Name()).RemoveAll()
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	err := s.libsDir.MkdirAll()
+	if err != nil {
+		return err
+	}
+	for _, name := range s.existing {
+		err = s.libsDir.Join(name).RemoveAll()
+		if err != nil {
+			return err
+		}
+		err = s.copyDir.Join(name).Rename(s.libsDir.Join(name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Discard removes the snapshot of the libraries
+func (s *LibrariesSnapshot) Discard() {
+	if s.copyDir != nil {
+		err := s.copyDir.Parent().RemoveAll()
+		if err != nil {
+			log.Printf("WARNING: failed to remove '%s': %s\n", s.copyDir.Parent(), err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package arduino
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	paths "github.com/arduino/go-paths-helper"
+)
+
+// writeLibrary writes a library with a header file of the given content
+func writeLibrary(t *testing.T, libsDir string, name string, content string) {
+	dir := filepath.Join(libsDir, name, "src")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".h"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readLibrary returns the content of the header file of a library or "" if it does not exist
+func readLibrary(libsDir string, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(libsDir, name, "src", name+".h"))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func TestLibrariesSnapshotRestore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "apm-snapshot-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	libsDir := filepath.Join(tmpDir, "libraries")
+	// installed without apm, it is not in any lock file
+	writeLibrary(t, libsDir, "Unlocked", "old")
+	writeLibrary(t, libsDir, "Other", "other")
+
+	snapshot, err := snapshotLibraries(paths.New(libsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Discard()
+
+	// a failed change overwrites the unlocked library like the installers do and installs a new one
+	if err := os.RemoveAll(filepath.Join(libsDir, "Unlocked")); err != nil {
+		t.Fatal(err)
+	}
+	writeLibrary(t, libsDir, "Unlocked", "new")
+	writeLibrary(t, libsDir, "New", "new")
+	if err := os.RemoveAll(filepath.Join(libsDir, "Other")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snapshot.restore(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readLibrary(libsDir, "Unlocked"); got != "old" {
+		t.Errorf("Unlocked = %q, want %q", got, "old")
+	}
+	if got := readLibrary(libsDir, "Other"); got != "other" {
+		t.Errorf("Other = %q, want %q", got, "other")
+	}
+	if _, err := os.Stat(filepath.Join(libsDir, "New")); !os.IsNotExist(err) {
+		t.Errorf("New is not removed")
+	}
+}
+
+func TestLibrariesSnapshotRestoreMissing(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "apm-snapshot-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	libsDir := filepath.Join(tmpDir, "libraries")
+
+	snapshot, err := snapshotLibraries(paths.New(libsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Discard()
+	writeLibrary(t, libsDir, "New", "new")
+
+	if err := snapshot.restore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(libsDir); !os.IsNotExist(err) {
+		t.Errorf("the libraries directory is not removed")
+	}
+}
@@ -0,0 +1,209 @@
+package arduino
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	aconfig "github.com/arduino/arduino-cli/configuration"
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/util"
+)
+
+// CheckVendoredDependencies returns the problems of the vendored libraries of the project,
+// the vendored libraries must be exactly the ones locked in the lock file
+func CheckVendoredDependencies(details *project.ProjectDetails, lock *project.ProjectLock) ([]string, error) {
+	var problems []string
+	if details.Vendor == "" {
+		return append(problems, fmt.Sprintf("no vendored libraries are set in %s, run 'apm vendor'", details.FileName())), nil
+	}
+	lockedDeps, ok := lock.LockedDependencies(details)
+	if !ok {
+		return append(problems, fmt.Sprintf("%s does not match %s", details.LockFileName(), details.FileName())), nil
+	}
+
+	vendorDir := paths.New(details.VendorDir())
+	// vendored libraries of the dependency groups which are not installed are kept
+	expected := excludedDirs(details, lock)
+	for _, locked := range lockedDeps {
+		if locked.Dir == "" || locked.Hash == "" {
+			problems = append(problems, fmt.Sprintf("no directory or hash of '%s' is locked", lockedName(locked)))
+			continue
+		}
+		expected[locked.Dir] = true
+		dir := vendorDir.Join(locked.Dir)
+		if !dir.IsDir() {
+			problems = append(problems, fmt.Sprintf("'%s' is not vendored", lockedName(locked)))
+			continue
+		}
+		hash, err := util.DirSha256(dir.String())
+		if err != nil {
+			return nil, err
+		}
+		if hash != locked.Hash {
+			problems = append(problems, fmt.Sprintf("vendored '%s' does not match %s", lockedName(locked), details.LockFileName()))
+		}
+	}
+
+	// libraries which are not needed anymore
+	if vendorDir.IsDir() {
+		files, err := ioutil.ReadDir(vendorDir.String())
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			// vendored libraries of the environments of the project
+			if _, ok := details.Environments[file.Name()]; ok {
+				continue
+			}
+			if !expected[file.Name()] {
+				problems = append(problems, fmt.Sprintf("'%s' is vendored, but it is not a dependency of the project", file.Name()))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// VendorDependencies copies every installed library of the project into its vendor directory. The installed libraries
+// must match their hashes in apm.lock, the new vendor directory is built next to the previous one and replaces it
+// only if every library could be copied.
+func (c *ArduinoCli) VendorDependencies(details *project.ProjectDetails, lock *project.ProjectLock) error {
+	lockedDeps, ok := lock.LockedDependencies(details)
+	if !ok {
+		return errors.New(fmt.Sprintf("%s does not match %s, please run 'apm install' first", details.LockFileName(), details.FileName()))
+	}
+	installedDirs, err := c.installedLibraryDirs()
+	if err != nil {
+		return err
+	}
+
+	// locally changed libraries are not vendored
+	var dirs []*paths.Path
+	for _, locked := range lockedDeps {
+		dir, err := libraryDir(locked, installedDirs)
+		if err != nil {
+			return err
+		}
+		if locked.Hash == "" {
+			return errors.New(fmt.Sprintf("no hash of '%s' is locked in %s, please run 'apm install' first", lockedName(locked), details.LockFileName()))
+		}
+		hash, err := util.DirSha256(dir.String())
+		if err != nil {
+			return err
+		}
+		if hash != locked.Hash {
+			return errors.New(fmt.Sprintf("installed '%s' does not match %s, it was changed locally (see 'apm verify')", lockedName(locked), details.LockFileName()))
+		}
+		dirs = append(dirs, dir)
+	}
+
+	vendorDir := paths.New(details.VendorDir())
+	err = vendorDir.Parent().MkdirAll()
+	if err != nil {
+		return err
+	}
+	tmpPath, err := ioutil.TempDir(vendorDir.Parent().String(), ".apm-vendor-")
+	if err != nil {
+		return err
+	}
+	tmpDir := paths.New(tmpPath)
+	defer tmpDir.RemoveAll()
+
+	// previously vendored libraries of the environments of the project and of the dependency groups
+	// which are not installed are kept
+	excluded := excludedDirs(details, lock)
+	if vendorDir.IsDir() {
+		files, err := ioutil.ReadDir(vendorDir.String())
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if _, ok := details.Environments[file.Name()]; ok || excluded[file.Name()] {
+				err = vendorDir.Join(file.Name()).CopyDirTo(tmpDir.Join(file.Name()))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for i, dir := range dirs {
+		log.Printf("Vendoring %s to %s...\n", lockedName(lockedDeps[i]), vendorDir.Join(dir.Base()))
+		err = dir.CopyDirTo(tmpDir.Join(dir.Base()))
+		if err != nil {
+			return err
+		}
+	}
+
+	// the previous vendor directory is replaced by the new one
+	previousDir := paths.New(tmpPath + ".previous")
+	if vendorDir.Exist() {
+		err = os.Rename(vendorDir.String(), previousDir.String())
+		if err != nil {
+			return err
+		}
+	}
+	err = os.Rename(tmpDir.String(), vendorDir.String())
+	if err != nil {
+		if previousDir.Exist() {
+			os.Rename(previousDir.String(), vendorDir.String())
+		}
+		return err
+	}
+	return previousDir.RemoveAll()
+}
+
+// installVendoredDependencies installs the vendored libraries without downloading anything
+func (c *ArduinoCli) installVendoredDependencies(details *project.ProjectDetails, lock *project.ProjectLock) error {
+	libsDir := aconfig.LibrariesDir(aconfig.Settings)
+	if libsDir == nil {
+		return errors.New("user directory not set")
+	}
+	err := libsDir.MkdirAll()
+	if err != nil {
+		return err
+	}
+	lockedDeps, _ := lock.LockedDependencies(details)
+	for _, locked := range lockedDeps {
+		log.Printf("Installing vendored %s...\n", lockedName(locked))
+		installPath := libsDir.Join(locked.Dir)
+		err = installPath.RemoveAll()
+		if err != nil {
+			return err
+		}
+		err = paths.New(details.VendorDir()).Join(locked.Dir).CopyDirTo(installPath)
+		if err != nil {
+			return err
+		}
+	}
+	lock.Dependencies = lockedDeps
+	return c.rescan()
+}
+
+// excludedDirs returns the locked directories of the dependency groups which are not installed
+func excludedDirs(details *project.ProjectDetails, lock *project.ProjectLock) map[string]bool {
+	result := make(map[string]bool)
+	lockedDeps, ok := lock.LockedDependencies(&project.ProjectDetails{Dependencies: details.ExcludedDependencies()})
+	if !ok {
+		return result
+	}
+	for _, locked := range lockedDeps {
+		if locked.Dir != "" {
+			result[locked.Dir] = true
+		}
+	}
+	return result
+}
+
+// lockedName returns the library name, git repository or zip file of a locked dependency
+func lockedName(locked project.LockedDependency) string {
+	if locked.Git != "" {
+		return locked.Git
+	}
+	if locked.Zip != "" {
+		return locked.Zip
+	}
+	return locked.Library
+}
@@ -0,0 +1,345 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	paths "github.com/arduino/go-paths-helper"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/util"
+)
+
+// name of the file which contains the entries of the cache and the known projects
+var CacheFileName string = "cache.json"
+
+// prefix of the keys of the index files, they are shared by every project
+const indexKeyPrefix = "index/"
+
+// Cache is a content addressed store of index files, library and core archives and git library sources.
+// Every entry is a set of files (or directories) stored by their sha256 in the objects directory.
+type Cache struct {
+	Dir      string                  `json:"-"`
+	Entries  map[string][]CachedFile `json:"entries"`
+	Projects []string                `json:"projects"`
+}
+
+// CachedFile is a file of a cache entry
+type CachedFile struct {
+	// path relative to the directory it is restored to
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+	// the object is a directory (e.g. sources of a git library)
+	IsDir bool `json:"dir,omitempty"`
+}
+
+func IndexKey(fileName string) string {
+	return indexKeyPrefix + fileName
+}
+
+func LibraryKey(name string, version string) string {
+	return fmt.Sprintf("library/%s@%s", name, version)
+}
+
+func PlatformKey(pkg string, arch string, version string) string {
+	return fmt.Sprintf("platform/%s:%s@%s", pkg, arch, version)
+}
+
+func GitKey(gitUrl string, commit string) string {
+	return fmt.Sprintf("git/%s@%s", gitUrl, commit)
+}
+
+// DefaultDir returns the directory of the cache, APM_CACHE_DIR or .apm/cache in the home directory
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("APM_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".apm", "cache"), nil
+}
+
+// Open opens the cache in the default directory
+func Open() (*Cache, error) {
+	dir, err := DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	result := &Cache{Dir: dir, Entries: make(map[string][]CachedFile)}
+	cacheFilePath := filepath.Join(dir, CacheFileName)
+	if util.FileExists(cacheFilePath) {
+		cacheFile, err := ioutil.ReadFile(cacheFilePath)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(cacheFile, result)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid '%s': %s", cacheFilePath, err))
+		}
+		if result.Entries == nil {
+			result.Entries = make(map[string][]CachedFile)
+		}
+	}
+	return result, nil
+}
+
+func (c *Cache) Save() error {
+	err := os.MkdirAll(c.Dir, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	fileData, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(c.Dir, CacheFileName), fileData, os.ModePerm)
+}
+
+func (c *Cache) objectPath(sha256 string) *paths.Path {
+	return paths.New(c.Dir, "objects", sha256)
+}
+
+// Has returns true if the entry and all of its objects are in the cache
+func (c *Cache) Has(key string) bool {
+	files, ok := c.Entries[key]
+	if !ok {
+		return false
+	}
+	for _, file := range files {
+		if !c.objectPath(file.Sha256).Exist() {
+			return false
+		}
+	}
+	return true
+}
+
+// Add stores the given files (relative to baseDir) as the entry of the key
+func (c *Cache) Add(key string, baseDir *paths.Path, files []string) error {
+	var entry []CachedFile
+	for _, file := range files {
+		source := baseDir.Join(file)
+		hash, err := util.FileSha256(source.String())
+		if err != nil {
+			return err
+		}
+		object := c.objectPath(hash)
+		if !object.Exist() {
+			err = object.Parent().MkdirAll()
+			if err != nil {
+				return err
+			}
+			err = source.CopyTo(object)
+			if err != nil {
+				return err
+			}
+		}
+		entry = append(entry, CachedFile{Path: filepath.ToSlash(file), Sha256: hash})
+	}
+	c.Entries[key] = entry
+	return nil
+}
+
+// AddDir stores a directory as the entry of the key
+func (c *Cache) AddDir(key string, dir *paths.Path) error {
+	hash, err := util.DirSha256(dir.String())
+	if err != nil {
+		return err
+	}
+	object := c.objectPath(hash)
+	if !object.Exist() {
+		err = object.Parent().MkdirAll()
+		if err != nil {
+			return err
+		}
+		err = dir.CopyDirTo(object)
+		if err != nil {
+			return err
+		}
+	}
+	c.Entries[key] = []CachedFile{{Path: dir.Base(), Sha256: hash, IsDir: true}}
+	return nil
+}
+
+// Restore copies the files of the entry into baseDir
+func (c *Cache) Restore(key string, baseDir *paths.Path) error {
+	if !c.Has(key) {
+		return errors.New(fmt.Sprintf("'%s' is not in the cache", key))
+	}
+	for _, file := range c.Entries[key] {
+		target := baseDir.Join(file.Path)
+		err := target.Parent().MkdirAll()
+		if err != nil {
+			return err
+		}
+		if file.IsDir {
+			err = target.RemoveAll()
+			if err != nil {
+				return err
+			}
+			err = c.objectPath(file.Sha256).CopyDirTo(target)
+		} else {
+			err = c.objectPath(file.Sha256).CopyTo(target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexKeys returns the keys of the cached index files
+func (c *Cache) IndexKeys() []string {
+	var result []string
+	for key := range c.Entries {
+		if strings.HasPrefix(key, indexKeyPrefix) {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Keys returns all keys of the cache in alphabetical order
+func (c *Cache) Keys() []string {
+	var result []string
+	for key := range c.Entries {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Size returns the size of the objects of an entry in bytes
+func (c *Cache) Size(key string) int64 {
+	var result int64
+	for _, file := range c.Entries[key] {
+		filepath.Walk(c.objectPath(file.Sha256).String(), func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				result += info.Size()
+			}
+			return nil
+		})
+	}
+	return result
+}
+
+// RegisterProject adds the project to the known projects, their locks are used by Gc
+func (c *Cache) RegisterProject(projectDir string) {
+	dir, err := filepath.Abs(projectDir)
+	if err != nil {
+		dir = projectDir
+	}
+	for _, known := range c.Projects {
+		if known == dir {
+			return
+		}
+	}
+	c.Projects = append(c.Projects, dir)
+}
+
+// LockKeys returns the keys of the entries needed to install a lock
+func LockKeys(lock *project.ProjectLock) []string {
+	var result []string
+	if lock.Board != nil {
+		result = append(result, PlatformKey(lock.Board.Package, lock.Board.Architecture, lock.Board.Version))
+	}
+	for _, platform := range lock.Platforms {
+		result = append(result, PlatformKey(platform.Package, platform.Architecture, platform.Version))
+	}
+	for _, locked := range lock.Dependencies {
+		if locked.Library != "" {
+			result = append(result, LibraryKey(locked.Library, locked.Version))
+		}
+		if locked.Git != "" {
+			result = append(result, GitKey(locked.Git, locked.Commit))
+		}
+	}
+	return result
+}
+
+// Gc removes the entries which are not referenced by the locks of any known project (index files are kept)
+// and the objects which are not used by any entry. The removed keys are returned.
+func (c *Cache) Gc() ([]string, error) {
+	referenced := make(map[string]bool)
+	var projects []string
+	for _, projectDir := range c.Projects {
+		// lock files of the project and its environments
+		lockFilePaths, err := filepath.Glob(filepath.Join(projectDir, project.LockFileName("*")))
+		if err != nil {
+			return nil, err
+		}
+		lockFilePaths = append(lockFilePaths, filepath.Join(projectDir, project.ProjectLockFileName))
+		found := false
+		for _, lockFilePath := range lockFilePaths {
+			if !util.FileExists(lockFilePath) {
+				continue
+			}
+			lockFile, err := ioutil.ReadFile(lockFilePath)
+			if err != nil {
+				return nil, err
+			}
+			lock := project.ProjectLock{}
+			err = json.Unmarshal(lockFile, &lock)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid '%s': %s", lockFilePath, err))
+			}
+			found = true
+			for _, key := range LockKeys(&lock) {
+				referenced[key] = true
+			}
+		}
+		if found {
+			projects = append(projects, projectDir)
+		}
+	}
+	c.Projects = projects
+
+	// remove entries
+	var removed []string
+	usedObjects := make(map[string]bool)
+	for _, key := range c.Keys() {
+		if !referenced[key] && !strings.HasPrefix(key, indexKeyPrefix) {
+			delete(c.Entries, key)
+			removed = append(removed, key)
+			continue
+		}
+		for _, file := range c.Entries[key] {
+			usedObjects[file.Sha256] = true
+		}
+	}
+
+	// remove objects
+	objectsDir := paths.New(c.Dir, "objects")
+	if objectsDir.IsDir() {
+		objects, err := ioutil.ReadDir(objectsDir.String())
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			if !usedObjects[object.Name()] {
+				err = objectsDir.Join(object.Name()).RemoveAll()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return removed, c.Save()
+}
+
+// Clear removes everything from the cache
+func (c *Cache) Clear() error {
+	c.Entries = make(map[string][]CachedFile)
+	err := paths.New(c.Dir, "objects").RemoveAll()
+	if err != nil {
+		return err
+	}
+	return c.Save()
+}
@@ -29,7 +29,7 @@ import (
 // addCmd represents the add command
 var addCmd = &cobra.Command{
 	Use:   "add",
-	Example: "apm add\napm add OneWire@2.3.5\napm add onewire\napm add onewire@latest",
+	Example: "apm add\napm add OneWire@2.3.5\napm add onewire\napm add onewire@latest\napm add OneWire@^2.3\napm add \"OneWire@>=2.3.0 <3\"\napm add --git https://github.com/PaulStoffregen/OneWire.git --ref v2.3.5\napm add --group dev ArduinoUnit",
 	Short: "Adding new libraries to the project",
 	Long:  `Adding new libraries to the Arduino project`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -38,9 +38,27 @@ var addCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// dependencies of the selected environment or group
+		env, err := project.GetEnvironmentName(cmd)
+		if err != nil {
+			return err
+		}
+		envDetails, err := details.ForEnvironment(env)
+		if err != nil {
+			return err
+		}
+		deps, err := dependenciesToEdit(cmd, details, env)
+		if err != nil {
+			return err
+		}
 
 		// init cli
-		cli := &arduino.ArduinoCli{}
+		cli := &arduino.ArduinoCli{IsolationDir: envDetails.IsolationDir()}
 		err = cli.Init()
 		if err != nil {
 			return err
@@ -52,8 +70,15 @@ var addCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		gitRef, err := cmd.Flags().GetString("ref")
+		if err != nil {
+			return err
+		}
 		if strings.TrimSpace(gitRepo) != "" {
-			return addGitRepoDep(cli, cmd, gitRepo, details)
+			return addGitRepoDep(cli, cmd, gitRepo, gitRef, details, deps, lock)
+		}
+		if strings.TrimSpace(gitRef) != "" {
+			return errors.New("--ref can only be used with --git")
 		}
 
 		// add zip library
@@ -62,7 +87,7 @@ var addCmd = &cobra.Command{
 			return err
 		}
 		if strings.TrimSpace(zipFile) != "" && util.FileExists(zipFile) {
-			return addZipDep(cli, cmd, zipFile, details)
+			return addZipDep(cli, cmd, zipFile, details, deps, lock)
 		}
 		if strings.TrimSpace(zipFile) != "" && !util.FileExists(zipFile) {
 			return errors.New(fmt.Sprintf("'%s' not found!", zipFile))
@@ -108,42 +133,23 @@ var addCmd = &cobra.Command{
 		// update changes
 		fmt.Printf("Adding %s@%s...\n", libName, libVersion)
 		hasDep := false
-		for i, dep := range details.Dependencies {
+		for i, dep := range *deps {
 			if dep.Library == libName {
 				hasDep = true
-				details.Dependencies[i].Version = libVersion
+				(*deps)[i].Version = libVersion
 			}
 		}
 		if !hasDep {
-			details.Dependencies = append(details.Dependencies, project.ProjectDependency{
+			*deps = append(*deps, project.ProjectDependency{
 				Library: libName,
 				Version: libVersion,
 			})
 		}
 
-		// check if we have any dependency mismatch with current libs
-		for _, dep := range details.Dependencies {
-			err = cli.CheckDependencyVersionMismatch(dep, details)
-			if err != nil {
-				return err
-			}
-		}
-
-		// update project file
-		err = project.UpdateProjectDetails(cmd, details)
-		if err != nil {
-			return err
-		}
-
-		// install dependencies
-		if details.Dependencies != nil && len(details.Dependencies) > 0 {
-			err = cli.InstallDependencies(details)
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
+		// resolve and install dependencies, then update project file
+		return transactional(cli, cmd, func() error {
+			return installAndUpdateProject(cli, cmd, details, lock)
+		})
 	},
 }
 
@@ -151,83 +157,64 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 
 	addCmd.Flags().StringP("git", "g", "", "Library from Git repository")
+	addCmd.Flags().StringP("ref", "r", "", "Branch, tag or commit of the Git repository")
 	addCmd.Flags().StringP("zip", "z", "", "Library from ZIP file")
+	addCmd.Flags().String("group", "", "Dependency group to add the library to (e.g. dev)")
 }
 
-func addGitRepoDep(cli *arduino.ArduinoCli, cmd *cobra.Command, gitRepo string, details *project.ProjectDetails) error {
-	fmt.Printf("Adding %s...\n", gitRepo)
+func addGitRepoDep(cli *arduino.ArduinoCli, cmd *cobra.Command, gitRepo string, gitRef string, details *project.ProjectDetails, deps *[]project.ProjectDependency, lock *project.ProjectLock) error {
+	if gitRef != "" {
+		fmt.Printf("Adding %s@%s...\n", gitRepo, gitRef)
+	} else {
+		fmt.Printf("Adding %s...\n", gitRepo)
+	}
 	hasDep := false
-	for i, dep := range details.Dependencies {
+	for i, dep := range *deps {
 		if dep.Git == gitRepo {
 			hasDep = true
-			details.Dependencies[i].Git = gitRepo
+			(*deps)[i].Git = gitRepo
+			(*deps)[i].Ref = gitRef
 		}
 	}
 	if !hasDep {
-		details.Dependencies = append(details.Dependencies, project.ProjectDependency{
+		*deps = append(*deps, project.ProjectDependency{
 			Git: gitRepo,
+			Ref: gitRef,
 		})
 	}
 
-	// check if we have any dependency mismatch with current libs
-	for _, dep := range details.Dependencies {
-		err := cli.CheckDependencyVersionMismatch(dep, details)
-		if err != nil {
-			return err
-		}
-	}
+	// always install the current commit of the ref
+	lock.UnlockGit(gitRepo)
 
-	// update project file
-	err := project.UpdateProjectDetails(cmd, details)
-	if err != nil {
-		return err
-	}
-
-	// install dependencies
-	if details.Dependencies != nil && len(details.Dependencies) > 0 {
-		err = cli.InstallDependencies(details)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	// resolve and install dependencies, then update project file
+	return transactional(cli, cmd, func() error {
+		return installAndUpdateProject(cli, cmd, details, lock)
+	})
 }
 
-func addZipDep(cli *arduino.ArduinoCli, cmd *cobra.Command, zipFile string, details *project.ProjectDetails) error {
+func addZipDep(cli *arduino.ArduinoCli, cmd *cobra.Command, zipFile string, details *project.ProjectDetails, deps *[]project.ProjectDependency, lock *project.ProjectLock) error {
 	fmt.Printf("Adding %s...\n", zipFile)
+	hash, err := util.FileSha256(zipFile)
+	if err != nil {
+		return err
+	}
 	hasDep := false
-	for i, dep := range details.Dependencies {
+	for i, dep := range *deps {
 		if dep.Zip == zipFile {
 			hasDep = true
-			details.Dependencies[i].Zip = zipFile
+			(*deps)[i].Zip = zipFile
+			(*deps)[i].Sha256 = hash
 		}
 	}
 	if !hasDep {
-		details.Dependencies = append(details.Dependencies, project.ProjectDependency{
-			Zip: zipFile,
+		*deps = append(*deps, project.ProjectDependency{
+			Zip:    zipFile,
+			Sha256: hash,
 		})
 	}
 
-	// check if we have any dependency mismatch with current libs
-	for _, dep := range details.Dependencies {
-		err := cli.CheckDependencyVersionMismatch(dep, details)
-		if err != nil {
-			return err
-		}
-	}
-
-	// update project file
-	err := project.UpdateProjectDetails(cmd, details)
-	if err != nil {
-		return err
-	}
-
-	// install dependencies
-	if details.Dependencies != nil && len(details.Dependencies) > 0 {
-		err = cli.InstallDependencies(details)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	// resolve and install dependencies, then update project file
+	return transactional(cli, cmd, func() error {
+		return installAndUpdateProject(cli, cmd, details, lock)
+	})
 }
@@ -0,0 +1,101 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+)
+
+// boardCmd represents the board command
+var boardCmd = &cobra.Command{
+	Use:   "board",
+	Short: "Board of the project",
+	Long:  `Show details of the board of the Arduino project`,
+}
+
+// boardOptionsCmd represents the board options command
+var boardOptionsCmd = &cobra.Command{
+	Use:     "options",
+	Example: "apm board options\napm board options --env esp32",
+	Short:   "List the menu options of the board",
+	Long: `List the valid menu options (e.g. flash size, partition scheme, CPU frequency) and their values
+of the board of the Arduino project from the installed core. The selected values are marked with '*',
+they can be set in the 'options' of the board in apm.json.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// project details
+		details, err := project.GetProjectEnvironment(cmd)
+		if err != nil {
+			return err
+		}
+		if details.Board == nil || details.Board.Package == "" {
+			return errors.New("no board is set in the project")
+		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// init cli
+		cli := &arduino.ArduinoCli{IsolationDir: details.IsolationDir()}
+		err = cli.Init()
+		if err != nil {
+			return err
+		}
+		defer cli.Destroy()
+
+		// the options are read from the installed core
+		err = installMissingDependencies(cli, cmd, details, lock)
+		if err != nil {
+			return err
+		}
+
+		options, err := cli.BoardOptions(details.Board)
+		if err != nil {
+			return err
+		}
+		if len(options) == 0 {
+			fmt.Println("The board has no options")
+			return nil
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "Option\tName\tValues")
+		for _, option := range options {
+			var values []string
+			for _, value := range option.Values {
+				if value.Selected {
+					values = append(values, "*"+value.Value)
+				} else {
+					values = append(values, value.Value)
+				}
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", option.Option, option.OptionLabel, strings.Join(values, ", "))
+		}
+		return writer.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(boardCmd)
+	boardCmd.AddCommand(boardOptionsCmd)
+}
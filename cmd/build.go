@@ -0,0 +1,104 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+)
+
+// buildCmd represents the build command
+var buildCmd = &cobra.Command{
+	Use:     "build",
+	Example: "apm build\napm build --clean\napm build --output-dir dist",
+	Short:   "Build the project",
+	Long: `Compile the sketch of the Arduino project for the board in apm.json and export the binaries
+into the output directory. Missing dependencies are installed first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		clean, err := cmd.Flags().GetBool("clean")
+		if err != nil {
+			return err
+		}
+		outputDir, err := cmd.Flags().GetString("output-dir")
+		if err != nil {
+			return err
+		}
+
+		// project details
+		details, err := project.GetProjectEnvironment(cmd)
+		if err != nil {
+			return err
+		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+		if details.Board != nil && outputDir != "" {
+			if details.Board.Build == nil {
+				details.Board.Build = &project.BuildOptions{}
+			}
+			details.Board.Build.OutputDir = outputDir
+		}
+
+		// init cli
+		cli := &arduino.ArduinoCli{IsolationDir: details.IsolationDir()}
+		err = cli.Init()
+		if err != nil {
+			return err
+		}
+		defer cli.Destroy()
+
+		// install missing dependencies
+		err = installMissingDependencies(cli, cmd, details, lock)
+		if err != nil {
+			return err
+		}
+
+		return cli.Compile(details, clean)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(buildCmd)
+
+	buildCmd.Flags().BoolP("clean", "c", false, "Clean the build directory before building")
+	buildCmd.Flags().StringP("output-dir", "o", "", "Directory of the compiled binaries (overrides output_dir in apm.json)")
+}
+
+// installMissingDependencies installs the board core and libraries of the project if any of them is not installed
+func installMissingDependencies(cli *arduino.ArduinoCli, cmd *cobra.Command, details *project.ProjectDetails, lock *project.ProjectLock) error {
+	missing, err := cli.HasMissingDependencies(details, lock)
+	if err != nil || !missing {
+		return err
+	}
+
+	// install board core package
+	if details.Board != nil && details.Board.Package != "" {
+		err = cli.InstallBoardCore(details, lock)
+		if err != nil {
+			return err
+		}
+	}
+
+	// install dependencies
+	err = installDependencies(cli, details, lock)
+	if err != nil {
+		return err
+	}
+	updateCache(cli, details, lock)
+	return project.UpdateProjectLock(cmd, lock)
+}
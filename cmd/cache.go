@@ -0,0 +1,115 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/ksrichard/apm/cache"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local package cache",
+	Long: `Manage the local package cache which contains the index files, library and core archives
+and git libraries installed by apm. It is used by 'apm install --offline'.
+The cache is in .apm/cache of the home directory or in APM_CACHE_DIR if it is set.`,
+}
+
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the entries of the local package cache",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		packageCache, err := cache.Open()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cache directory: %s\n", packageCache.Dir)
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "ENTRY\tFILES\tSIZE\t")
+		var total int64
+		for _, key := range packageCache.Keys() {
+			size := packageCache.Size(key)
+			total += size
+			fmt.Fprintf(writer, "%s\t%d\t%s\t\n", key, len(packageCache.Entries[key]), formatSize(size))
+		}
+		writer.Flush()
+		fmt.Printf("%d entries, %s\n", len(packageCache.Entries), formatSize(total))
+		return nil
+	},
+}
+
+// cacheGcCmd represents the cache gc command
+var cacheGcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove cache entries not used by any known project",
+	Long: `Remove the entries of the local package cache which are not referenced by the apm.lock of any project
+installed with apm. Index files are always kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		packageCache, err := cache.Open()
+		if err != nil {
+			return err
+		}
+		removed, err := packageCache.Gc()
+		if err != nil {
+			return err
+		}
+		for _, key := range removed {
+			fmt.Printf("Removed %s\n", key)
+		}
+		fmt.Printf("%d entries removed\n", len(removed))
+		return nil
+	},
+}
+
+// cacheClearCmd represents the cache clear command
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove everything from the local package cache",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		packageCache, err := cache.Open()
+		if err != nil {
+			return err
+		}
+		err = packageCache.Clear()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Cache cleared")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheGcCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
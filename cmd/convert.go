@@ -0,0 +1,49 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:     "convert FORMAT",
+	Example: "apm convert yaml\napm convert toml\napm convert json",
+	Short:   "Convert the project file to another format",
+	Long: `Convert the project file (apm.json, apm.yaml or apm.toml) to another format: json, yaml or toml.
+The project file in the previous format is removed, comments are kept when converting between YAML and TOML.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		details, err := project.GetProjectDetails(cmd)
+		if err != nil {
+			return err
+		}
+		filePath, err := project.ConvertProjectDetails(details, args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Project file converted to '%s'\n", filePath)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+}
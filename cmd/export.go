@@ -0,0 +1,133 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/util"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the project for another tool",
+	Long:  `Create the project file of another tool from the project`,
+}
+
+// exportSketchYamlCmd represents the export sketch-yaml command
+var exportSketchYamlCmd = &cobra.Command{
+	Use:     "sketch-yaml",
+	Example: "apm export sketch-yaml\napm export sketch-yaml --profile uno\napm export sketch-yaml --output ../sketch.yaml",
+	Short:   "Export the project as sketch.yaml build profiles",
+	Long: `Write the board and the libraries of the project with the versions locked in apm.lock as a build profile
+into the sketch.yaml of the project, so they can be used by 'arduino-cli compile --profile' and the Arduino IDE.
+Every environment becomes a profile too. Other profiles of an existing sketch.yaml are kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profileName, err := cmd.Flags().GetString("profile")
+		if err != nil {
+			return err
+		}
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+		details, err := project.GetProjectDetails(cmd)
+		if err != nil {
+			return err
+		}
+		if output == "" {
+			output = filepath.Join(details.Dir, project.SketchYamlFileName)
+		}
+
+		// the project and every environment with their lock files
+		envs := []string{""}
+		var envNames []string
+		for env := range details.Environments {
+			envNames = append(envNames, env)
+		}
+		sort.Strings(envNames)
+		envs = append(envs, envNames...)
+		var names []string
+		profiles := make(map[string]*project.SketchProfile)
+		for _, env := range envs {
+			name := env
+			if env == "" {
+				name = profileName
+			}
+			envDetails, err := details.ForEnvironment(env)
+			if err != nil {
+				return err
+			}
+			groups, err := project.GetDependencyGroups(cmd, envDetails)
+			if err != nil {
+				return err
+			}
+			envDetails = envDetails.WithGroups(groups)
+			lock, err := project.ReadProjectLock(filepath.Join(details.Dir, project.LockFileName(env)))
+			if err != nil {
+				return err
+			}
+			profile, warnings, err := project.SketchProfileOf(envDetails, lock)
+			if err != nil {
+				if env == "" && len(envNames) > 0 {
+					log.Printf("WARNING: profile '%s' is skipped: %s\n", name, err)
+					continue
+				}
+				return errors.New(fmt.Sprintf("profile '%s': %s", name, err))
+			}
+			for _, warning := range warnings {
+				log.Printf("WARNING: profile '%s': %s\n", name, warning)
+			}
+			names = append(names, name)
+			profiles[name] = profile
+		}
+
+		var previous []byte
+		if util.FileExists(output) {
+			previous, err = ioutil.ReadFile(output)
+			if err != nil {
+				return err
+			}
+		}
+		fileData, err := project.MarshalSketchYaml(previous, names, profiles)
+		if err != nil {
+			return err
+		}
+		err = util.WriteFileAtomic(output, fileData, os.ModePerm)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Profiles %v written to %s\n", names, output)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportSketchYamlCmd)
+
+	exportSketchYamlCmd.Flags().String("profile", "default", "Name of the profile of the project, environments are exported with their names")
+	exportSketchYamlCmd.Flags().StringP("output", "o", "", "Path of the written sketch.yaml (default: sketch.yaml in the project directory)")
+}
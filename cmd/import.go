@@ -0,0 +1,121 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a project of another tool",
+	Long:  `Create the project file of apm from the project file of another tool`,
+}
+
+// importPlatformioCmd represents the import platformio command
+var importPlatformioCmd = &cobra.Command{
+	Use:     "platformio [PATH]",
+	Example: "apm import platformio\napm import platformio ../old_project\napm import platformio ../old_project/platformio.ini --format yaml",
+	Short:   "Import a PlatformIO project",
+	Long: `Create the project file from the platformio.ini of a PlatformIO project (the project directory by default).
+The platform and board of the environments are mapped onto boards, lib_deps (library registry names with versions,
+git URLs and local zip files) onto dependencies and -D build flags onto defines. The default environment becomes the project,
+if there are more environments, every one of them becomes an environment of apm.
+Everything which can not be translated is listed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, err := project.GetProjectDir(cmd)
+		if err != nil {
+			return err
+		}
+		path := projectDir
+		if len(args) > 0 {
+			path = args[0]
+		}
+		details, untranslated, err := project.ImportPlatformio(path, projectDir)
+		if err != nil {
+			return err
+		}
+		filePath, err := writeNewProjectFile(cmd, details)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s has been created\n", filePath)
+		printUntranslated(untranslated)
+		return nil
+	},
+}
+
+// importSketchYamlCmd represents the import sketch-yaml command
+var importSketchYamlCmd = &cobra.Command{
+	Use:     "sketch-yaml [PATH]",
+	Example: "apm import sketch-yaml\napm import sketch-yaml ../other_sketch/sketch.yaml",
+	Short:   "Import sketch.yaml build profiles",
+	Long: `Create the project file from the build profiles of a sketch.yaml (in the project directory by default).
+The fqbn, the pinned platform (with its platform_index_url) and the pinned libraries of the default profile become
+the board and the dependencies of the project, if there are more profiles every one of them becomes an environment.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		projectDir, err := project.GetProjectDir(cmd)
+		if err != nil {
+			return err
+		}
+		path := projectDir
+		if len(args) > 0 {
+			path = args[0]
+		}
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, project.SketchYamlFileName)
+		}
+		details, untranslated, err := project.ImportSketchYaml(path)
+		if err != nil {
+			return err
+		}
+		filePath, err := writeNewProjectFile(cmd, details)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s has been created\n", filePath)
+		printUntranslated(untranslated)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importPlatformioCmd)
+	importCmd.AddCommand(importSketchYamlCmd)
+
+	importPlatformioCmd.Flags().StringP("format", "f", project.FormatJson, "Format of the project file: json, yaml or toml")
+	importSketchYamlCmd.Flags().StringP("format", "f", project.FormatJson, "Format of the project file: json, yaml or toml")
+}
+
+// printUntranslated lists what could not be imported
+func printUntranslated(untranslated []string) {
+	if len(untranslated) == 0 {
+		return
+	}
+	fmt.Println("Could not translate:")
+	for _, message := range untranslated {
+		fmt.Printf("  - %s\n", message)
+	}
+}
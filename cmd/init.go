@@ -16,47 +16,299 @@ limitations under the License.
 package cmd
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/ksrichard/apm/arduino"
 	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/service"
 	"github.com/ksrichard/apm/util"
 	"github.com/spf13/cobra"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
+// starterSketch is the content of the sketch created by 'apm init --sketch'
+var starterSketch = `void setup() {
+}
+
+void loop() {
+}
+`
+
 // initCmd represents the init command
 var initCmd = &cobra.Command{
-	Use:   "init",
+	Use: "init",
+	Example: "apm init\n" +
+		"apm init --format yaml\n" +
+		"apm init -i\n" +
+		"apm init --from-sketch --board arduino:avr:uno\n" +
+		"apm init --board arduino:avr:uno --library OneWire --library DallasTemperature@^3.9 --sketch\n" +
+		"apm init --board esp8266:esp8266:nodemcuv2 --core-version ^3.0 --board-manager-url https://arduino.esp8266.com/stable/package_esp8266com_index.json",
 	Short: "Init APM project",
-	Long: `Init Arduino Package Manager project`,
+	Long: `Init Arduino Package Manager project.
+With -i a wizard asks for the board (including the ones of a board manager URL), the version of its core package,
+the initial libraries and whether a starter sketch should be created. The same can be set with flags.
+With --from-sketch the libraries included by the existing sketch are added (see 'apm scan').`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		projectDir, err := project.GetProjectDir(cmd)
 		if err != nil {
 			return err
 		}
-		jsonFilePath := fmt.Sprintf("%s/%s", projectDir, project.ProjectDetailsFileName)
-		if util.FileExists(jsonFilePath) {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+		_, err = project.FormatFileName(format)
+		if err != nil {
+			return err
+		}
+		existingFile, err := project.FindProjectFile(projectDir)
+		if err != nil {
+			return err
+		}
+		if existingFile != "" {
 			return errors.New(fmt.Sprintf("'%s' is already initialized", projectDir))
-		} else {
-			details := project.ProjectDetails{
-				Board:        &project.ProjectBoard{},
-				Dependencies: []project.ProjectDependency{},
+		}
+
+		interactive, err := cmd.Flags().GetBool("interactive")
+		if err != nil {
+			return err
+		}
+		boardFqbn, err := cmd.Flags().GetString("board")
+		if err != nil {
+			return err
+		}
+		boardManagerUrl, err := cmd.Flags().GetString("board-manager-url")
+		if err != nil {
+			return err
+		}
+		coreVersion, err := cmd.Flags().GetString("core-version")
+		if err != nil {
+			return err
+		}
+		createSketch, err := cmd.Flags().GetBool("sketch")
+		if err != nil {
+			return err
+		}
+		libraries, err := cmd.Flags().GetStringArray("library")
+		if err != nil {
+			return err
+		}
+		fromSketch, err := cmd.Flags().GetBool("from-sketch")
+		if err != nil {
+			return err
+		}
+		if boardFqbn == "" && !interactive && (boardManagerUrl != "" || cmd.Flags().Changed("core-version")) {
+			return errors.New("--board-manager-url and --core-version can only be used with --board")
+		}
+
+		details := project.ProjectDetails{
+			Board:        &project.ProjectBoard{},
+			Dependencies: []project.ProjectDependency{},
+		}
+		if interactive || boardFqbn != "" || len(libraries) > 0 || fromSketch {
+			// init cli
+			cli := &arduino.ArduinoCli{}
+			err = cli.Init()
+			if err != nil {
+				return err
+			}
+			defer cli.Destroy()
+
+			if interactive {
+				createSketch, err = initWizard(cli, &details, boardManagerUrl, createSketch)
+			} else {
+				err = initFromFlags(cli, &details, boardFqbn, boardManagerUrl, coreVersion, libraries)
 			}
-			fileData, err := json.MarshalIndent(details, "", "    ")
 			if err != nil {
 				return err
 			}
-			err = ioutil.WriteFile(jsonFilePath, fileData, os.ModePerm)
+
+			// libraries included by the sketch
+			if fromSketch {
+				details.Dir = projectDir
+				result, err := scanSketch(cli, &details)
+				if err != nil {
+					return err
+				}
+				for _, lib := range result.Libraries {
+					if !hasLibraryDependency(details.Dependencies, lib.Name) {
+						addInitLibrary(&details, lib.Name, lib.Version)
+					}
+				}
+			}
+		}
+
+		_, err = writeNewProjectFile(cmd, &details)
+		if err != nil {
+			return err
+		}
+		if createSketch {
+			err = writeStarterSketch(projectDir)
 			if err != nil {
 				return err
 			}
-			return nil
 		}
+		if details.Board.Package != "" || len(details.Dependencies) > 0 {
+			fmt.Println("Run 'apm install' to install the board core package and the libraries")
+		}
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().StringP("format", "f", project.FormatJson, "Format of the project file: json, yaml or toml")
+	initCmd.Flags().BoolP("interactive", "i", false, "Select the board, the core version and the libraries interactively")
+	initCmd.Flags().StringP("board", "b", "", "Board of the project: PACKAGE:ARCHITECTURE or PACKAGE:ARCHITECTURE:BOARD")
+	initCmd.Flags().StringP("board-manager-url", "u", "", "Board manager URL of the core package of the board")
+	initCmd.Flags().StringP("core-version", "c", "latest", "Version of the core package of the board")
+	initCmd.Flags().BoolP("sketch", "s", false, "Create a starter sketch")
+	initCmd.Flags().StringArrayP("library", "l", nil, "Library of the project: LIBRARY_NAME or LIBRARY_NAME@VERSION (can be repeated)")
+	initCmd.Flags().Bool("from-sketch", false, "Add the libraries included by the sketch of the project directory")
+}
+
+// initFromFlags sets the board and the libraries of a new project from the flags of 'apm init'
+func initFromFlags(cli *arduino.ArduinoCli, details *project.ProjectDetails, boardFqbn string, boardManagerUrl string, coreVersion string, libraries []string) error {
+	if boardFqbn != "" {
+		board, err := service.BoardFromFqbn(boardFqbn)
+		if err != nil {
+			return err
+		}
+		board.Version = coreVersion
+		board.BoardManagerUrls = boardManagerUrls(boardManagerUrl)
+		err = cli.UpdateCoreIndex(board)
+		if err != nil {
+			return err
+		}
+		err = service.CheckIfBoardValid(cli, board)
+		if err != nil {
+			return err
+		}
+		details.Board = board
+	}
+
+	if len(libraries) > 0 {
+		err := cli.UpdateLibraryIndex()
+		if err != nil {
+			return err
+		}
+	}
+	for _, library := range libraries {
+		nameAndVer := strings.Split(library, "@")
+		if len(nameAndVer) > 2 {
+			return errors.New("please provide the library in the following form: LIBRARY_NAME or LIBRARY_NAME@VERSION")
+		}
+		libVersion := "latest"
+		if len(nameAndVer) == 2 {
+			libVersion = nameAndVer[1]
+		}
+		libName, err := service.CheckIfLibraryValid(cli, nameAndVer[0], libVersion, 5)
+		if err != nil {
+			return err
+		}
+		addInitLibrary(details, libName, libVersion)
+	}
+	return nil
+}
+
+// initWizard asks for the board, the core version, the libraries and the starter sketch of a new project,
+// it returns whether the starter sketch should be created
+func initWizard(cli *arduino.ArduinoCli, details *project.ProjectDetails, boardManagerUrl string, createSketch bool) (bool, error) {
+	selectBoard, err := util.Confirm("Select a board")
+	if err != nil {
+		return false, err
+	}
+	if selectBoard {
+		boardManagerUrl, err = util.Input("Board manager URL (leave empty for the Arduino boards)", boardManagerUrl)
+		if err != nil {
+			return false, err
+		}
+		err = cli.UpdateCoreIndex(&project.ProjectBoard{BoardManagerUrls: boardManagerUrls(boardManagerUrl)})
+		if err != nil {
+			return false, err
+		}
+		board, err := service.SelectBoard(cli)
+		if err != nil {
+			return false, err
+		}
+		board.BoardManagerUrls = boardManagerUrls(boardManagerUrl)
+		board.Version, err = service.SelectBoardVersion(cli, board)
+		if err != nil {
+			return false, err
+		}
+		if board.Board == "" {
+			fmt.Printf("The core package %s:%s is not installed yet, please set the board ID of the board in the project file after 'apm install'\n", board.Package, board.Architecture)
+		}
+		details.Board = board
+	}
+
+	// libraries
+	err = cli.UpdateLibraryIndex()
+	if err != nil {
+		return false, err
+	}
+	for {
+		addLibrary, err := util.Confirm("Add a library")
+		if err != nil {
+			return false, err
+		}
+		if !addLibrary {
+			break
+		}
+		libName, libVersion, err := service.SelectLibrary(cli)
+		if err != nil {
+			return false, err
+		}
+		addInitLibrary(details, libName, libVersion)
+	}
+
+	if createSketch {
+		return true, nil
+	}
+	return util.Confirm("Create a starter sketch")
+}
+
+// addInitLibrary adds a library to the project or changes its version if it is already added
+func addInitLibrary(details *project.ProjectDetails, libName string, libVersion string) {
+	for i, dep := range details.Dependencies {
+		if strings.ToLower(dep.Library) == strings.ToLower(libName) {
+			details.Dependencies[i].Version = libVersion
+			return
+		}
+	}
+	details.Dependencies = append(details.Dependencies, project.ProjectDependency{
+		Library: libName,
+		Version: libVersion,
+	})
+}
+
+// writeStarterSketch creates an empty sketch named after the project directory unless the project has a sketch already
+func writeStarterSketch(projectDir string) error {
+	absDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return err
+	}
+	existing, err := filepath.Glob(filepath.Join(absDir, "*.ino"))
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		fmt.Printf("'%s' already exists, the starter sketch is not created\n", existing[0])
+		return nil
+	}
+	sketchFile := filepath.Join(absDir, filepath.Base(absDir)+".ino")
+	fmt.Printf("Creating %s...\n", sketchFile)
+	return ioutil.WriteFile(sketchFile, []byte(starterSketch), os.ModePerm)
+}
+
+// boardManagerUrls returns the board manager URLs of a board of a new project
+func boardManagerUrls(boardManagerUrl string) []string {
+	if boardManagerUrl == "" {
+		return nil
+	}
+	return []string{boardManagerUrl}
 }
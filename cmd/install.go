@@ -16,6 +16,8 @@ limitations under the License.
 package cmd
 
 import (
+	"errors"
+	"fmt"
 	"github.com/ksrichard/apm/arduino"
 	"github.com/ksrichard/apm/project"
 	"github.com/spf13/cobra"
@@ -25,15 +27,40 @@ import (
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install dependencies of project",
-	Long: `Install dependencies of the Arduino project`,
+	Long: `Install dependencies of the Arduino project.
+Everything installed is stored in the local package cache, so it can be installed later with --offline.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// project details
-		details, err := project.GetProjectDetails(cmd)
+		details, err := project.GetProjectEnvironment(cmd)
 		if err != nil {
 			return err
 		}
 
-		cli := &arduino.ArduinoCli{}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// everything must be in the local package cache in offline mode
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			return err
+		}
+		if offline {
+			missing, err := arduino.MissingFromCache(details, lock)
+			if err != nil {
+				return err
+			}
+			if len(missing) > 0 {
+				fmt.Println("Missing from the local package cache:")
+				for _, item := range missing {
+					fmt.Printf("  - %s\n", item)
+				}
+				return errors.New("the project can not be installed offline, please run 'apm install' with internet access first")
+			}
+		}
+
+		cli := &arduino.ArduinoCli{IsolationDir: details.IsolationDir(), Offline: offline}
 		err = cli.Init()
 		if err != nil {
 			return err
@@ -42,24 +69,30 @@ var installCmd = &cobra.Command{
 
 		// install board core package
 		if details.Board != nil && details.Board.Package != "" {
-			err = cli.InstallBoardCore(details)
+			err = cli.InstallBoardCore(details, lock)
 			if err != nil {
 				return err
 			}
 		}
 
 		// install dependencies
-		if details.Dependencies != nil && len(details.Dependencies) > 0 {
-			err = cli.InstallDependencies(details)
-			if err != nil {
-				return err
-			}
+		err = installDependencies(cli, details, lock)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		// cache what has been installed
+		if !offline {
+			updateCache(cli, details, lock)
+		}
+
+		// save what has been installed
+		return project.UpdateProjectLock(cmd, lock)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(installCmd)
+
+	installCmd.Flags().Bool("offline", false, "Install only from the local package cache")
 }
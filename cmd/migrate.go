@@ -0,0 +1,76 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:     "migrate",
+	Example: "apm migrate\napm migrate --dry-run",
+	Short:   "Migrate the project file to the current schema version",
+	Long: `Upgrade the project file step by step from its schema_version (1 if it is not set) to the schema version
+of this version of apm. The changes are printed before the project file is rewritten, formatting and comments are kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+
+		document, err := project.ReadProjectDocument(cmd)
+		if err != nil {
+			return err
+		}
+		migrated, changes, err := document.Migrate()
+		if err != nil {
+			return err
+		}
+		if migrated == document {
+			fmt.Printf("'%s' has the current schema version %d\n", document.File, project.CurrentSchemaVersion)
+			return nil
+		}
+
+		version, _ := document.SchemaVersion()
+		fmt.Printf("Migrating '%s' from schema version %d to %d:\n", document.File, version, project.CurrentSchemaVersion)
+		for _, migration := range project.PendingMigrations(version) {
+			fmt.Printf("  %d -> %d: %s\n", migration.From, migration.From+1, migration.Description)
+		}
+		fmt.Println("Changes:")
+		for _, change := range changes {
+			fmt.Printf("  %s\n", change)
+		}
+		if dryRun {
+			return nil
+		}
+
+		err = project.MigrateProjectFile(cmd, migrated)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("'%s' is migrated to schema version %d\n", document.File, project.CurrentSchemaVersion)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().BoolP("dry-run", "n", false, "Only print the changes, do not change the project file")
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/service"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// outdatedCmd represents the outdated command
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "List outdated dependencies",
+	Long: `List the board core and libraries of the Arduino project which have newer releases
+and the git dependencies which have newer tags.
+Exits with a non-zero exit code if anything is outdated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// project details
+		details, err := project.GetProjectEnvironment(cmd)
+		if err != nil {
+			return err
+		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// init cli
+		cli := &arduino.ArduinoCli{IsolationDir: details.IsolationDir()}
+		err = cli.Init()
+		if err != nil {
+			return err
+		}
+		defer cli.Destroy()
+
+		// update indexes
+		if details.Board != nil && details.Board.Package != "" {
+			err = cli.UpdateCoreIndex(details.Board)
+			if err != nil {
+				return err
+			}
+		}
+		err = cli.UpdateLibraryIndex()
+		if err != nil {
+			return err
+		}
+
+		deps, err := service.GetDependencyVersions(cli, details, lock)
+		if err != nil {
+			return err
+		}
+
+		outdated := 0
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tSPEC\tINSTALLED\tWANTED\tLATEST\t")
+		for _, dep := range deps {
+			name := dep.Name
+			if dep.Board {
+				name = fmt.Sprintf("%s (board)", dep.Name)
+			}
+			if dep.IsOutdated() {
+				outdated++
+				name = fmt.Sprintf("* %s", name)
+			}
+			installed := dep.Installed
+			if dep.Git && len(installed) > 7 {
+				installed = installed[:7]
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t\n", name, dep.Spec, orDash(installed), orDash(dep.Wanted), orDash(dep.Latest))
+		}
+		writer.Flush()
+
+		// newer tags of git dependencies
+		for _, dep := range deps {
+			if len(dep.NewerTags) > 0 {
+				fmt.Printf("Newer tags of %s: %s\n", dep.Name, strings.Join(dep.NewerTags, ", "))
+			}
+		}
+
+		if outdated > 0 {
+			return errors.New(fmt.Sprintf("%d dependencies are outdated", outdated))
+		}
+		fmt.Println("All dependencies are up to date")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(outdatedCmd)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+// installAndUpdateProject installs the dependencies of the project (as seen by the environment and the dependency groups
+// selected with --env, --with and --without), then saves apm.json and the lock file together
+func installAndUpdateProject(cli *arduino.ArduinoCli, cmd *cobra.Command, details *project.ProjectDetails, lock *project.ProjectLock) error {
+	envDetails, err := details.ForCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	// install dependencies
+	err = installDependencies(cli, envDetails, lock)
+	if err != nil {
+		return err
+	}
+
+	updateCache(cli, envDetails, lock)
+
+	// update project and lock file
+	err = project.UpdateProjectDetails(cmd, details)
+	if err != nil {
+		return err
+	}
+	return project.UpdateProjectLock(cmd, lock)
+}
+
+// dependenciesToEdit returns the dependency list of the group selected with --group, or the one of the environment
+// selected with --env if no group is selected
+func dependenciesToEdit(cmd *cobra.Command, details *project.ProjectDetails, env string) (*[]project.ProjectDependency, error) {
+	deps, err := details.DependenciesOf(env)
+	if err != nil {
+		return nil, err
+	}
+	group, err := cmd.Flags().GetString("group")
+	if err != nil || group == "" {
+		return deps, err
+	}
+	with, err := cmd.Flags().GetStringSlice("with")
+	if err != nil {
+		return nil, err
+	}
+	if details.IsOptionalGroup(group) && !containsIgnoreCase(with, group) {
+		log.Printf("'%s' is an optional dependency group, it is only installed with --with %s\n", group, group)
+	}
+	return details.GroupDependenciesOf(group), nil
+}
+
+// installDependencies installs the dependencies of the project as seen by the selected environment and dependency
+// groups, the locked entries of the groups which are not installed are kept in the lock file
+func installDependencies(cli *arduino.ArduinoCli, details *project.ProjectDetails, lock *project.ProjectLock) error {
+	previous := append([]project.LockedDependency{}, lock.Dependencies...)
+	if len(details.Dependencies) > 0 {
+		err := cli.InstallDependencies(details, lock)
+		if err != nil {
+			return err
+		}
+	} else {
+		lock.Dependencies = []project.LockedDependency{}
+	}
+	lock.KeepLocked(previous, details.ExcludedDependencies())
+	return nil
+}
+
+// transactional runs a change of the project (e.g. adding a library): if it fails, the project file, the lock file
+// and the installed libraries are restored as they were before the change
+func transactional(cli *arduino.ArduinoCli, cmd *cobra.Command, change func() error) error {
+	files, err := project.SnapshotFiles(cmd)
+	if err != nil {
+		return err
+	}
+	libraries, err := cli.SnapshotLibraries()
+	if err != nil {
+		return err
+	}
+	defer libraries.Discard()
+
+	err = change()
+	if err == nil {
+		return nil
+	}
+	log.Printf("ERROR: %s, rolling back...\n", err)
+	if restoreErr := files.Restore(); restoreErr != nil {
+		log.Printf("WARNING: failed to restore the project files: %s\n", restoreErr)
+	}
+	if restoreErr := libraries.Restore(); restoreErr != nil {
+		log.Printf("WARNING: failed to restore the installed libraries: %s\n", restoreErr)
+	}
+	return err
+}
+
+// writeNewProjectFile writes the project file of a new project with the current schema version in the format selected with --format,
+// the path of the project file is returned
+func writeNewProjectFile(cmd *cobra.Command, details *project.ProjectDetails) (string, error) {
+	projectDir, err := project.GetProjectDir(cmd)
+	if err != nil {
+		return "", err
+	}
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return "", err
+	}
+	fileName, err := project.FormatFileName(format)
+	if err != nil {
+		return "", err
+	}
+	existingFile, err := project.FindProjectFile(projectDir)
+	if err != nil {
+		return "", err
+	}
+	if existingFile != "" {
+		return "", errors.New(fmt.Sprintf("'%s' is already initialized", projectDir))
+	}
+	details.SchemaVersion = project.CurrentSchemaVersion
+	fileData, err := project.MarshalProjectDetails(details, strings.ToLower(format), nil)
+	if err != nil {
+		return "", err
+	}
+	filePath := fmt.Sprintf("%s/%s", projectDir, fileName)
+	return filePath, ioutil.WriteFile(filePath, fileData, os.ModePerm)
+}
+
+// updateCache stores what has been installed in the local package cache, failures are only reported
+func updateCache(cli *arduino.ArduinoCli, details *project.ProjectDetails, lock *project.ProjectLock) {
+	err := cli.UpdateCache(details, lock)
+	if err != nil {
+		log.Printf("WARNING: failed to update the local package cache: %s\n", err)
+	}
+}
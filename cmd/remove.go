@@ -38,7 +38,8 @@ var removeCmd = &cobra.Command{
 		"apm remove \"https://github.com/jandrassy/ArduinoOTA\"\n" +
 		"apm remove https://github.com/jandrassy/ArduinoOTA\n" +
 		"apm remove ArduinoOTA.zip\n" +
-		"apm remove \"ArduinoOTA.zip\"",
+		"apm remove \"ArduinoOTA.zip\"\n" +
+		"apm remove --group dev ArduinoUnit",
 	Short: "Remove library from the project",
 	Long:  `Remove library from the Arduino project`,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -47,9 +48,27 @@ var removeCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// dependencies of the selected environment or group
+		env, err := project.GetEnvironmentName(cmd)
+		if err != nil {
+			return err
+		}
+		envDetails, err := details.ForEnvironment(env)
+		if err != nil {
+			return err
+		}
+		deps, err := dependenciesToEdit(cmd, details, env)
+		if err != nil {
+			return err
+		}
 
 		// init cli
-		cli := &arduino.ArduinoCli{}
+		cli := &arduino.ArduinoCli{IsolationDir: envDetails.IsolationDir()}
 		err = cli.Init()
 		if err != nil {
 			return err
@@ -61,7 +80,7 @@ var removeCmd = &cobra.Command{
 		if len(args) < 1 {
 			fmt.Println("No library provided...")
 			items := make(map[string]interface{})
-			for _, dep := range details.Dependencies {
+			for _, dep := range *deps {
 				libTitle := ""
 				if dep.Library == "" && dep.Git != "" {
 					libTitle = dep.Git
@@ -91,7 +110,7 @@ var removeCmd = &cobra.Command{
 			libToRemove = selectedLib.(*project.ProjectDependency)
 		} else { // library name provided
 			libToRemoveArg := args[0]
-			for _, dep := range details.Dependencies {
+			for _, dep := range *deps {
 				if strings.ToLower(dep.Library) == strings.ToLower(libToRemoveArg) ||
 					dep.Git == libToRemoveArg ||
 					dep.Zip == libToRemoveArg {
@@ -117,41 +136,32 @@ var removeCmd = &cobra.Command{
 		log.Printf("Removing '%s'...", libName)
 
 		// remove from project file
-		for i, dep := range details.Dependencies {
+		for i, dep := range *deps {
 			if (dep.Library != "" && dep.Library == libToRemove.Library) ||
 				(dep.Git != "" && dep.Git == libToRemove.Git) ||
 				(dep.Zip != "" && dep.Zip == libToRemove.Zip) {
-				details.Dependencies = removeFromDeps(details.Dependencies, i)
+				*deps = removeFromDeps(*deps, i)
 				break
 			}
 		}
 
-		// update project file
-		err = project.UpdateProjectDetails(cmd, details)
-		if err != nil {
-			return err
-		}
-
-		// uninstall dependency
-		err = cli.UninstallDependency(libToRemove)
-		if err != nil {
-			return err
-		}
-
-		// install dependencies
-		if details.Dependencies != nil && len(details.Dependencies) > 0 {
-			err = cli.InstallDependencies(details)
+		return transactional(cli, cmd, func() error {
+			// uninstall dependency
+			err := cli.UninstallDependency(libToRemove)
 			if err != nil {
 				return err
 			}
-		}
 
-		return nil
+			// install dependencies and update project file
+			return installAndUpdateProject(cli, cmd, details, lock)
+		})
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(removeCmd)
+
+	removeCmd.Flags().String("group", "", "Dependency group to remove the library from (e.g. dev)")
 }
 
 func removeFromDeps(slice []project.ProjectDependency, i int) []project.ProjectDependency {
@@ -45,4 +45,7 @@ func init() {
 		os.Exit(1)
 	}
 	rootCmd.PersistentFlags().StringP("project-dir", "p", currentDir, "Project directory to use")
+	rootCmd.PersistentFlags().StringP("env", "e", "", "Environment of the project to use")
+	rootCmd.PersistentFlags().StringSlice("with", nil, "Optional dependency groups to install")
+	rootCmd.PersistentFlags().StringSlice("without", nil, "Dependency groups not to install")
 }
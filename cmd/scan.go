@@ -0,0 +1,152 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/service"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// scanCmd represents the scan command
+var scanCmd = &cobra.Command{
+	Use:     "scan",
+	Example: "apm scan\napm scan --dry-run",
+	Short:   "Add the libraries included by the sketch to the project",
+	Long: `Scan the #include directives of the sketch (.ino, .cpp, .h files) and add the libraries providing the headers
+to the project file with their installed versions (or latest if they are not installed).
+Headers of the board core package are skipped, if more than one library provides a header it can be selected.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+
+		// project details
+		details, err := project.GetProjectDetails(cmd)
+		if err != nil {
+			return err
+		}
+		env, err := project.GetEnvironmentName(cmd)
+		if err != nil {
+			return err
+		}
+		envDetails, err := details.ForEnvironment(env)
+		if err != nil {
+			return err
+		}
+		deps, err := details.DependenciesOf(env)
+		if err != nil {
+			return err
+		}
+
+		// init cli
+		cli := &arduino.ArduinoCli{IsolationDir: envDetails.IsolationDir()}
+		err = cli.Init()
+		if err != nil {
+			return err
+		}
+		defer cli.Destroy()
+
+		result, err := scanSketch(cli, envDetails)
+		if err != nil {
+			return err
+		}
+
+		// add the libraries which are not dependencies of the project (or of any of its dependency groups) yet
+		allDeps := envDetails.WithGroups(envDetails.GroupNames()).Dependencies
+		added := 0
+		for _, lib := range result.Libraries {
+			if hasLibraryDependency(allDeps, lib.Name) {
+				continue
+			}
+			fmt.Printf("Adding %s@%s...\n", lib.Name, lib.Version)
+			*deps = append(*deps, project.ProjectDependency{Library: lib.Name, Version: lib.Version})
+			added++
+		}
+		if added == 0 {
+			fmt.Println("All libraries of the sketch are dependencies of the project")
+			return nil
+		}
+		if dryRun {
+			return nil
+		}
+		return project.UpdateProjectDetails(cmd, details)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+
+	scanCmd.Flags().BoolP("dry-run", "n", false, "Only print the libraries, do not change the project file")
+}
+
+// scanSketch returns the libraries included by the sketch of the project and prints them
+func scanSketch(cli *arduino.ArduinoCli, details *project.ProjectDetails) (*service.ScanResult, error) {
+	// build output and vendored libraries are not part of the sketch
+	root := *details
+	root.Environment = ""
+	skipDirs := []string{root.OutputDir()}
+	if details.Vendor != "" {
+		skipDirs = append(skipDirs, filepath.Join(details.Dir, details.Vendor))
+	}
+	includes, err := service.ScanIncludes(details.Dir, skipDirs)
+	if err != nil {
+		return nil, err
+	}
+	err = cli.UpdateLibraryIndex()
+	if err != nil {
+		return nil, err
+	}
+	result, err := service.ResolveIncludes(cli, details, includes)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "LIBRARY\tVERSION\tHEADERS\t")
+	for _, lib := range result.Libraries {
+		version := lib.Version
+		if !lib.Installed {
+			version += " (not installed)"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t\n", lib.Name, version, strings.Join(lib.Headers, ", "))
+	}
+	writer.Flush()
+	if len(result.CoreHeaders) > 0 {
+		fmt.Printf("Headers of the board core: %s\n", strings.Join(result.CoreHeaders, ", "))
+	}
+	if len(result.UnknownHeaders) > 0 {
+		fmt.Printf("Headers not provided by any library: %s\n", strings.Join(result.UnknownHeaders, ", "))
+	}
+	return result, nil
+}
+
+// hasLibraryDependency returns true if the library is a dependency (ignoring case)
+func hasLibraryDependency(deps []project.ProjectDependency, name string) bool {
+	for _, dep := range deps {
+		if strings.ToLower(dep.Library) == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
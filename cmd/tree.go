@@ -0,0 +1,83 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/service"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+// treeCmd represents the tree command
+var treeCmd = &cobra.Command{
+	Use: "tree",
+	Example: "apm tree\n" +
+		"apm tree --format dot | dot -Tsvg > dependencies.svg\n" +
+		"apm tree --format mermaid",
+	Short: "Show the dependency tree of the project",
+	Long: `Show the full dependency tree of the Arduino project with the resolved versions,
+including the libraries needed by the libraries in apm.json.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+
+		graph, err := getDependencyGraph(cmd)
+		if err != nil {
+			return err
+		}
+
+		output, err := graph.FormatTree(format)
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+
+	treeCmd.Flags().StringP("format", "f", "text", fmt.Sprintf("Output format (%s)", strings.Join(service.GraphFormats, ", ")))
+}
+
+// getDependencyGraph returns the resolved dependency graph of the project
+func getDependencyGraph(cmd *cobra.Command) (*service.DependencyGraph, error) {
+	// project details
+	details, err := project.GetProjectEnvironment(cmd)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := project.GetProjectLock(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	// init cli
+	cli := &arduino.ArduinoCli{IsolationDir: details.IsolationDir()}
+	err = cli.Init()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Destroy()
+
+	return service.GetDependencyGraph(cli, details, lock)
+}
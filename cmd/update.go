@@ -0,0 +1,225 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/service"
+	"github.com/ksrichard/apm/util"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+// updateCmd represents the update command
+var updateCmd = &cobra.Command{
+	Use: "update [LIBRARY...]",
+	Example: "apm update\n" +
+		"apm update OneWire\n" +
+		"apm update --board\n" +
+		"apm update --latest DallasTemperature\n" +
+		"apm update -i",
+	Short: "Update dependencies of the project",
+	Long: `Update all or the selected libraries (and optionally the board core) of the Arduino project
+to the newest versions allowed by their versions in apm.json and save the new versions.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		updateBoard, err := cmd.Flags().GetBool("board")
+		if err != nil {
+			return err
+		}
+		ignoreSpecs, err := cmd.Flags().GetBool("latest")
+		if err != nil {
+			return err
+		}
+		interactive, err := cmd.Flags().GetBool("interactive")
+		if err != nil {
+			return err
+		}
+
+		// project details
+		details, err := project.GetProjectDetails(cmd)
+		if err != nil {
+			return err
+		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// project as seen by the selected environment and dependency groups
+		env, err := project.GetEnvironmentName(cmd)
+		if err != nil {
+			return err
+		}
+		envDetails, err := details.ForCommand(cmd)
+		if err != nil {
+			return err
+		}
+		hasBoard := envDetails.Board != nil && envDetails.Board.Package != ""
+		if updateBoard && !hasBoard {
+			return errors.New("no board is set in the project")
+		}
+
+		// check that all the given libraries are in the project
+		for _, arg := range args {
+			found := false
+			for _, dep := range envDetails.Dependencies {
+				if strings.ToLower(dep.Library) == strings.ToLower(arg) {
+					found = true
+				}
+			}
+			if !found {
+				return errors.New(fmt.Sprintf("failed to find '%s' library in the project", arg))
+			}
+		}
+
+		// init cli
+		cli := &arduino.ArduinoCli{IsolationDir: envDetails.IsolationDir()}
+		err = cli.Init()
+		if err != nil {
+			return err
+		}
+		defer cli.Destroy()
+
+		// update indexes
+		if hasBoard {
+			err = cli.UpdateCoreIndex(envDetails.Board)
+			if err != nil {
+				return err
+			}
+		}
+		err = cli.UpdateLibraryIndex()
+		if err != nil {
+			return err
+		}
+
+		// collect possible updates
+		deps, err := service.GetDependencyVersions(cli, envDetails, lock)
+		if err != nil {
+			return err
+		}
+		var updates []service.DependencyVersions
+		for _, dep := range deps {
+			if dep.Git || (dep.Board && !updateBoard) {
+				continue
+			}
+			if !dep.Board && len(args) > 0 && !containsIgnoreCase(args, dep.Name) {
+				continue
+			}
+			if ignoreSpecs {
+				dep.Wanted = dep.Latest
+			}
+			if dep.CanUpdate() {
+				updates = append(updates, dep)
+			}
+		}
+		if len(updates) == 0 {
+			fmt.Println("All dependencies are up to date")
+			return nil
+		}
+
+		// select updates to apply
+		if interactive {
+			var items []string
+			var selected []bool
+			for _, update := range updates {
+				items = append(items, fmt.Sprintf("%s %s -> %s", update.Name, orDash(update.Installed), update.Wanted))
+				selected = append(selected, true)
+			}
+			selected, err = util.MultiSelect("Select updates to apply", items, selected)
+			if err != nil {
+				return err
+			}
+			var selectedUpdates []service.DependencyVersions
+			for i, update := range updates {
+				if selected[i] {
+					selectedUpdates = append(selectedUpdates, update)
+				}
+			}
+			updates = selectedUpdates
+		}
+
+		// write new versions
+		var depLists []*[]project.ProjectDependency
+		for _, group := range envDetails.SelectedGroups {
+			depLists = append(depLists, details.GroupDependenciesOf(group))
+		}
+		depLists = append(depLists, &details.Dependencies)
+		if env != "" {
+			envDeps, err := details.DependenciesOf(env)
+			if err != nil {
+				return err
+			}
+			depLists = append(depLists, envDeps)
+		}
+		for _, update := range updates {
+			if update.Board {
+				newSpec := project.BumpVersionSpec(envDetails.Board.Version, update.Wanted)
+				fmt.Printf("Updating %s %s -> %s (%s -> %s)\n", update.Name, orDash(update.Installed), update.Wanted, envDetails.Board.Version, newSpec)
+				envDetails.Board.Version = newSpec
+				lock.Board = nil
+				continue
+			}
+			// the library is updated where it is declared, environment dependencies override shared ones,
+			// shared ones override the ones of the dependency groups
+			updated := false
+			for j := len(depLists) - 1; j >= 0 && !updated; j-- {
+				deps := depLists[j]
+				for i, dep := range *deps {
+					if strings.ToLower(dep.Library) == strings.ToLower(update.Name) {
+						newSpec := project.BumpVersionSpec(dep.Version, update.Wanted)
+						fmt.Printf("Updating %s %s -> %s (%s -> %s)\n", update.Name, orDash(update.Installed), update.Wanted, dep.Version, newSpec)
+						(*deps)[i].Version = newSpec
+						lock.Unlock(dep.Library)
+						updated = true
+					}
+				}
+			}
+		}
+
+		return transactional(cli, cmd, func() error {
+			// install board core package
+			if hasBoard && lock.Board == nil {
+				err := cli.InstallBoardCore(envDetails, lock)
+				if err != nil {
+					return err
+				}
+			}
+
+			// install dependencies and update project file
+			return installAndUpdateProject(cli, cmd, details, lock)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().BoolP("board", "b", false, "Update the board core package too")
+	updateCmd.Flags().BoolP("latest", "l", false, "Update to the latest versions ignoring the versions in apm.json")
+	updateCmd.Flags().BoolP("interactive", "i", false, "Select the updates to apply")
+}
+
+func containsIgnoreCase(values []string, value string) bool {
+	for _, v := range values {
+		if strings.ToLower(v) == strings.ToLower(value) {
+			return true
+		}
+	}
+	return false
+}
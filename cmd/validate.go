@@ -0,0 +1,88 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:     "validate",
+	Example: "apm validate\napm validate --offline\napm validate --print-schema > apm.schema.json",
+	Short:   "Validate the project file",
+	Long: `Validate apm.json against its JSON Schema and check mutually exclusive fields, missing versions,
+unknown board core packages and unreachable zip files. All problems are reported with their positions.
+Every other command runs the same validation (except the board core package check) before doing anything.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		printSchema, err := cmd.Flags().GetBool("print-schema")
+		if err != nil {
+			return err
+		}
+		if printSchema {
+			fmt.Print(project.Schema)
+			return nil
+		}
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			return err
+		}
+
+		document, err := project.GetProjectDocument(cmd)
+		if err != nil {
+			return err
+		}
+		problems := document.Validate()
+
+		// board core packages can only be checked if the project file could be read
+		if len(problems) == 0 && !offline {
+			details, err := document.Details()
+			if err != nil {
+				return err
+			}
+			cli := &arduino.ArduinoCli{IsolationDir: details.IsolationDir()}
+			err = cli.Init()
+			if err != nil {
+				return err
+			}
+			defer cli.Destroy()
+			problems, err = cli.ValidateBoards(details)
+			if err != nil {
+				return err
+			}
+			project.SortProblems(problems)
+		}
+
+		if len(problems) > 0 {
+			fmt.Println(project.FormatProblems(problems))
+			return errors.New(fmt.Sprintf("%d problem(s) found in '%s'", len(problems), document.File))
+		}
+		fmt.Printf("'%s' is valid\n", document.File)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().Bool("offline", false, "Do not check the board core packages in the package indexes")
+	validateCmd.Flags().Bool("print-schema", false, "Print the JSON Schema of apm.json")
+}
@@ -0,0 +1,111 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+)
+
+// vendorCmd represents the vendor command
+var vendorCmd = &cobra.Command{
+	Use:     "vendor",
+	Example: "apm vendor\napm vendor --check",
+	Short:   "Copy dependencies into the project",
+	Long: `Copy every resolved library of the Arduino project (including git and zip dependencies)
+into the vendor directory of the project, so 'apm install' can install them without downloading anything.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		check, err := cmd.Flags().GetBool("check")
+		if err != nil {
+			return err
+		}
+
+		// project details
+		details, err := project.GetProjectDetails(cmd)
+		if err != nil {
+			return err
+		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// project as seen by the selected environment and dependency groups
+		envDetails, err := details.ForCommand(cmd)
+		if err != nil {
+			return err
+		}
+
+		// check vendored libraries
+		if check {
+			problems, err := arduino.CheckVendoredDependencies(envDetails, lock)
+			if err != nil {
+				return err
+			}
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+			if len(problems) > 0 {
+				return errors.New("vendored libraries do not match the project, please run 'apm vendor'")
+			}
+			fmt.Println("Vendored libraries are up to date")
+			return nil
+		}
+
+		// init cli
+		cli := &arduino.ArduinoCli{IsolationDir: envDetails.IsolationDir()}
+		err = cli.Init()
+		if err != nil {
+			return err
+		}
+		defer cli.Destroy()
+
+		// install dependencies from their sources, so the lock matches the project
+		vendor := envDetails.Vendor
+		if vendor == "" {
+			vendor = project.VendorDirName
+		}
+		envDetails.Vendor = ""
+		err = installDependencies(cli, envDetails, lock)
+		if err != nil {
+			return err
+		}
+
+		// copy libraries
+		envDetails.Vendor = vendor
+		details.Vendor = vendor
+		err = cli.VendorDependencies(envDetails, lock)
+		if err != nil {
+			return err
+		}
+
+		// update project and lock file
+		err = project.UpdateProjectDetails(cmd, details)
+		if err != nil {
+			return err
+		}
+		return project.UpdateProjectLock(cmd, lock)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vendorCmd)
+
+	vendorCmd.Flags().BoolP("check", "c", false, "Fail if the vendored libraries do not match the project")
+}
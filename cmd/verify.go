@@ -0,0 +1,94 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify installed dependencies",
+	Long: `Verify the zip files of the Arduino project against their sha256 in apm.json and
+re-hash every installed library directory against the hashes recorded in apm.lock
+to detect local changes. Exits with a non-zero exit code if anything does not match.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// project details
+		details, err := project.GetProjectEnvironment(cmd)
+		if err != nil {
+			return err
+		}
+		lock, err := project.GetProjectLock(cmd)
+		if err != nil {
+			return err
+		}
+
+		// init cli
+		cli := &arduino.ArduinoCli{IsolationDir: details.IsolationDir()}
+		err = cli.Init()
+		if err != nil {
+			return err
+		}
+		defer cli.Destroy()
+
+		failed := 0
+
+		// zip files
+		for _, dep := range details.Dependencies {
+			err = arduino.VerifyZipFile(dep)
+			if err != nil {
+				fmt.Println(err)
+				failed++
+			}
+		}
+
+		// installed libraries, dependency groups which are not installed are not verified
+		installed := lock
+		if lockedDeps, ok := lock.LockedDependencies(details); ok {
+			installed = &project.ProjectLock{Board: lock.Board, Dependencies: lockedDeps}
+		}
+		verifications, err := cli.VerifyInstalledDependencies(installed)
+		if err != nil {
+			return err
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tSTATUS\tDIRECTORY\t")
+		for _, verification := range verifications {
+			if verification.Status != arduino.VerificationOk {
+				failed++
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t\n", verification.Name, verification.Status, orDash(verification.Dir))
+		}
+		writer.Flush()
+
+		if failed > 0 {
+			return errors.New(fmt.Sprintf("%d dependencies failed verification", failed))
+		}
+		fmt.Println("All dependencies are verified")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
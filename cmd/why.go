@@ -0,0 +1,60 @@
+/*
+Copyright © 2021 Richard Klavora <klavorasr@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/ksrichard/apm/service"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+// whyCmd represents the why command
+var whyCmd = &cobra.Command{
+	Use:     "why LIBRARY",
+	Example: "apm why OneWire",
+	Short:   "Show why a library is needed by the project",
+	Long:    `Show every path from apm.json to the given library in the dependency graph of the Arduino project.`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			return err
+		}
+
+		graph, err := getDependencyGraph(cmd)
+		if err != nil {
+			return err
+		}
+
+		paths, err := graph.Why(args[0])
+		if err != nil {
+			return err
+		}
+		output, err := graph.FormatPaths(paths, format)
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whyCmd)
+
+	whyCmd.Flags().StringP("format", "f", "text", fmt.Sprintf("Output format (%s)", strings.Join(service.GraphFormats, ", ")))
+}
@@ -4,12 +4,17 @@ go 1.15
 
 require (
 	github.com/arduino/arduino-cli v0.0.0-20210413144851-088d4276190d
+	github.com/arduino/go-paths-helper v1.4.0
 	github.com/manifoldco/promptui v0.8.0
 	github.com/mitchellh/gox v1.0.1 // indirect
+	github.com/pelletier/go-toml v1.2.0
 	github.com/phayes/freeport v0.0.0-20180830031419-95f893ade6f2
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/viper v1.7.1 // indirect
+	go.bug.st/relaxed-semver v0.0.0-20190922224835-391e10178d18
 	google.golang.org/grpc v1.27.0
+	gopkg.in/src-d/go-git.v4 v4.13.1
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
 )
 
 replace go.bug.st/downloader/v2 => ./go-downloader/
@@ -0,0 +1,261 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Document is a parsed project file which keeps the positions of its values
+type Document struct {
+	File string
+	// directory of the project
+	Dir    string
+	Format string
+	data   []byte
+	root   *jsonNode
+	// project file before the migration to the current schema version, nil if it was not migrated
+	original *Document
+	// nodes changed by the migrations with the nodes they replace (nil for new nodes)
+	replaced map[*jsonNode]*jsonNode
+	// descriptions of the changes of the migrations
+	changes []string
+}
+
+// jsonNode is a value of a project file (in any format) with its position in the file,
+// byte offsets are only set for JSON files and styles only for YAML and TOML files
+type jsonNode struct {
+	Kind   string
+	Line   int
+	Column int
+	Start  int
+	End    int
+	Style  string
+	Fields []*jsonField
+	Items  []*jsonNode
+	Value  interface{}
+}
+
+// styles of the values of YAML and TOML project files, they are kept when the project file is rewritten
+const (
+	styleDoubleQuoted = "double-quoted"
+	styleSingleQuoted = "single-quoted"
+	// YAML literal block scalars and TOML multi-line strings
+	styleLiteral = "literal"
+	styleFolded  = "folded"
+	// YAML flow collections and TOML inline arrays and tables
+	styleFlow = "flow"
+	// TOML inline arrays and tables written in more lines
+	styleMultiline = "multiline"
+)
+
+// jsonField is a key and value of an object
+type jsonField struct {
+	Key       string
+	KeyLine   int
+	KeyColumn int
+	KeyStart  int
+	KeyEnd    int
+	Value     *jsonNode
+}
+
+// ParseDocument parses a project file in the format of its file name, syntax errors are reported with their position
+func ParseDocument(file string, data []byte) (*Document, error) {
+	switch FileFormat(file) {
+	case FormatYaml:
+		return parseYamlDocument(file, data)
+	case FormatToml:
+		return parseTomlDocument(file, data)
+	}
+	return parseJsonDocument(file, data)
+}
+
+func parseJsonDocument(file string, data []byte) (*Document, error) {
+	result := &Document{File: file, Format: FormatJson, data: data}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	root, err := result.parseNode(decoder)
+	if err == nil {
+		if _, err = decoder.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = errors.New(fmt.Sprintf("%s: unexpected data after the end of the document", result.position(int(decoder.InputOffset()))))
+		}
+	}
+	if err != nil {
+		if syntaxError, ok := err.(*json.SyntaxError); ok {
+			return nil, errors.New(fmt.Sprintf("%s: %s", result.position(int(syntaxError.Offset)), syntaxError))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errors.New(fmt.Sprintf("%s: unexpected end of the document", result.position(len(data))))
+		}
+		return nil, err
+	}
+	result.root = root
+	return result, nil
+}
+
+func (d *Document) parseNode(decoder *json.Decoder) (*jsonNode, error) {
+	start := d.tokenStart(int(decoder.InputOffset()))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	node := &jsonNode{Start: start, Value: token}
+	node.Line, node.Column = d.lineColumn(start)
+	switch value := token.(type) {
+	case json.Delim:
+		if value == '{' {
+			node.Kind = "object"
+			for decoder.More() {
+				keyStart := d.tokenStart(int(decoder.InputOffset()))
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				keyEnd := int(decoder.InputOffset())
+				fieldValue, err := d.parseNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				keyLine, keyColumn := d.lineColumn(keyStart)
+				node.Fields = append(node.Fields, &jsonField{Key: key.(string), KeyLine: keyLine, KeyColumn: keyColumn, KeyStart: keyStart, KeyEnd: keyEnd, Value: fieldValue})
+			}
+		} else {
+			node.Kind = "array"
+			for decoder.More() {
+				item, err := d.parseNode(decoder)
+				if err != nil {
+					return nil, err
+				}
+				node.Items = append(node.Items, item)
+			}
+		}
+		// closing delimiter
+		_, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		node.Value = nil
+	case string:
+		node.Kind = "string"
+	case json.Number:
+		node.Kind = "number"
+	case bool:
+		node.Kind = "boolean"
+	case nil:
+		node.Kind = "null"
+	}
+	node.End = int(decoder.InputOffset())
+	return node, nil
+}
+
+// tokenStart skips the whitespaces and separators before the next token
+func (d *Document) tokenStart(offset int) int {
+	for offset < len(d.data) && strings.IndexByte(" \t\r\n,:", d.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineColumn returns the line and column of a byte offset
+func (d *Document) lineColumn(offset int) (int, int) {
+	if offset > len(d.data) {
+		offset = len(d.data)
+	}
+	line := bytes.Count(d.data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(d.data[:offset], '\n')
+	return line, column
+}
+
+// position returns the file, line and column of a byte offset
+func (d *Document) position(offset int) string {
+	line, column := d.lineColumn(offset)
+	return d.linePosition(line, column)
+}
+
+func (d *Document) linePosition(line int, column int) string {
+	return fmt.Sprintf("%s:%d:%d", d.File, line, column)
+}
+
+// field returns the value of a field of an object node or nil if it is not set
+func (n *jsonNode) field(key string) *jsonNode {
+	if n == nil || n.Kind != "object" {
+		return nil
+	}
+	// the last one wins like in encoding/json
+	var result *jsonNode
+	for _, field := range n.Fields {
+		if field.Key == key {
+			result = field.Value
+		}
+	}
+	return result
+}
+
+// stringField returns a string field of an object node or an empty string if it is not a set string
+func (n *jsonNode) stringField(key string) string {
+	value := n.field(key)
+	if value == nil || value.Kind != "string" {
+		return ""
+	}
+	return value.Value.(string)
+}
+
+// lookup returns the node of a path of object keys (string) and array indexes (int) or nil if it does not exist
+func (d *Document) lookup(path ...interface{}) *jsonNode {
+	node := d.root
+	for _, element := range path {
+		switch key := element.(type) {
+		case string:
+			node = node.field(key)
+		case int:
+			if node == nil || node.Kind != "array" || key < 0 || key >= len(node.Items) {
+				return nil
+			}
+			node = node.Items[key]
+		}
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// Has returns true if the path exists in the document
+func (d *Document) Has(path ...interface{}) bool {
+	return d != nil && d.lookup(path...) != nil
+}
+
+// Position returns the position of the value of a path or of its nearest existing parent
+func (d *Document) Position(path ...interface{}) string {
+	if d == nil {
+		return ProjectDetailsFileName
+	}
+	for i := len(path); i >= 0; i-- {
+		if node := d.lookup(path[:i]...); node != nil {
+			return d.linePosition(node.Line, node.Column)
+		}
+	}
+	return d.linePosition(1, 1)
+}
+
+// formatPath formats a path like board.options or dependencies[1].library
+func formatPath(path []interface{}) string {
+	result := ""
+	for _, element := range path {
+		switch key := element.(type) {
+		case string:
+			if result != "" {
+				result += "."
+			}
+			result += key
+		case int:
+			result += fmt.Sprintf("[%d]", key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,151 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GetEnvironmentName returns the environment selected with --env or an empty string
+func GetEnvironmentName(cmd *cobra.Command) (string, error) {
+	return cmd.Flags().GetString("env")
+}
+
+// GetProjectEnvironment returns the project as seen by the environment and the dependency groups selected with
+// --env, --with and --without
+func GetProjectEnvironment(cmd *cobra.Command) (*ProjectDetails, error) {
+	details, err := GetProjectDetails(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return details.ForCommand(cmd)
+}
+
+// ForCommand returns the project as seen by the environment and the dependency groups selected with
+// --env, --with and --without
+func (d *ProjectDetails) ForCommand(cmd *cobra.Command) (*ProjectDetails, error) {
+	env, err := GetEnvironmentName(cmd)
+	if err != nil {
+		return nil, err
+	}
+	envDetails, err := d.ForEnvironment(env)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := GetDependencyGroups(cmd, envDetails)
+	if err != nil {
+		return nil, err
+	}
+	return envDetails.WithGroups(groups), nil
+}
+
+// EnvironmentNames returns the names of the environments in alphabetical order
+func (d *ProjectDetails) EnvironmentNames() []string {
+	var result []string
+	for name := range d.Environments {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ForEnvironment returns the project as seen by the given environment: its own board (or the shared one if not set)
+// and the shared dependencies merged with its own dependencies, which override shared ones of the same library.
+// The project itself is returned for an empty name.
+func (d *ProjectDetails) ForEnvironment(name string) (*ProjectDetails, error) {
+	if name == "" {
+		return d, nil
+	}
+	env, ok := d.Environments[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("environment '%s' not found, available environments: %s", name, strings.Join(d.EnvironmentNames(), ", ")))
+	}
+	result := *d
+	result.Environments = nil
+	result.Environment = name
+	if env.Board != nil {
+		result.Board = env.Board
+	}
+	result.Dependencies = []ProjectDependency{}
+	for _, dep := range d.Dependencies {
+		if !containsDependency(env.Dependencies, dep) {
+			result.Dependencies = append(result.Dependencies, dep)
+		}
+	}
+	result.Dependencies = append(result.Dependencies, env.Dependencies...)
+	return &result, nil
+}
+
+// DependenciesOf returns the dependency list of the given environment (or the shared one for an empty name) to be edited
+func (d *ProjectDetails) DependenciesOf(name string) (*[]ProjectDependency, error) {
+	if name == "" {
+		return &d.Dependencies, nil
+	}
+	env, ok := d.Environments[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("environment '%s' not found, available environments: %s", name, strings.Join(d.EnvironmentNames(), ", ")))
+	}
+	return &env.Dependencies, nil
+}
+
+// SameDependency returns true if both dependencies are the same library, git repository or zip file
+func SameDependency(a ProjectDependency, b ProjectDependency) bool {
+	return (a.Library != "" && strings.ToLower(a.Library) == strings.ToLower(b.Library)) ||
+		(a.Git != "" && a.Git == b.Git) ||
+		(a.Zip != "" && a.Zip == b.Zip)
+}
+
+func containsDependency(deps []ProjectDependency, dep ProjectDependency) bool {
+	for _, d := range deps {
+		if SameDependency(d, dep) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetailsOfEnvironments returns the project of the boards and dependencies of imported environments: the first environment
+// is the project and dependencies of every environment are shared. If there are more environments, every one of them
+// becomes an environment of the project with its board (if it differs) and its additional dependencies.
+func DetailsOfEnvironments(envs []string, boards map[string]*ProjectBoard, deps map[string][]ProjectDependency) *ProjectDetails {
+	details := &ProjectDetails{Board: boards[envs[0]], Dependencies: []ProjectDependency{}}
+	for _, dep := range deps[envs[0]] {
+		shared := true
+		for _, env := range envs[1:] {
+			shared = shared && containsEqualDependency(deps[env], dep)
+		}
+		if shared {
+			details.Dependencies = append(details.Dependencies, dep)
+		}
+	}
+	if len(envs) == 1 {
+		return details
+	}
+	details.Environments = make(map[string]*ProjectEnvironment)
+	for _, env := range envs {
+		environment := &ProjectEnvironment{}
+		if !reflect.DeepEqual(boards[env], details.Board) {
+			environment.Board = boards[env]
+		}
+		for _, dep := range deps[env] {
+			if !containsEqualDependency(details.Dependencies, dep) {
+				environment.Dependencies = append(environment.Dependencies, dep)
+			}
+		}
+		details.Environments[env] = environment
+	}
+	return details
+}
+
+// containsEqualDependency returns true if the dependency is in the list with the same version
+func containsEqualDependency(deps []ProjectDependency, dep ProjectDependency) bool {
+	for _, d := range deps {
+		if d == dep {
+			return true
+		}
+	}
+	return false
+}
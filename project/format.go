@@ -0,0 +1,241 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/ksrichard/apm/util"
+)
+
+const (
+	FormatJson = "json"
+	FormatYaml = "yaml"
+	FormatToml = "toml"
+)
+
+// Formats are the supported formats of the project file
+var Formats = []string{FormatJson, FormatYaml, FormatToml}
+
+// ProjectDetailsFileNames are the supported names of the project file, the format is detected by the file name
+var ProjectDetailsFileNames = []string{"apm.json", "apm.yaml", "apm.yml", "apm.toml"}
+
+// FileFormat returns the format of a project file by its file name
+func FileFormat(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		return FormatYaml
+	case ".toml":
+		return FormatToml
+	}
+	return FormatJson
+}
+
+// FormatFileName returns the name of the project file in the given format
+func FormatFileName(format string) (string, error) {
+	for _, known := range Formats {
+		if known == strings.ToLower(format) {
+			return "apm." + known, nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf("unknown format '%s', available formats: %s", format, strings.Join(Formats, ", ")))
+}
+
+// FindProjectFile returns the path of the project file in the project directory or an empty string if there is none
+func FindProjectFile(projectDir string) (string, error) {
+	var found []string
+	for _, fileName := range ProjectDetailsFileNames {
+		if filePath := fmt.Sprintf("%s/%s", projectDir, fileName); util.FileExists(filePath) {
+			found = append(found, filePath)
+		}
+	}
+	if len(found) > 1 {
+		return "", errors.New(fmt.Sprintf("more than one project file found: %s, please remove all but one", strings.Join(found, ", ")))
+	}
+	if len(found) == 0 {
+		return "", nil
+	}
+	return found[0], nil
+}
+
+// comment holds the comments of a value: the lines before it, at the end of its line and the lines after it
+type comment struct {
+	Head string
+	Line string
+	Foot string
+}
+
+// comments of a project file by the path of their values (see commentPath)
+type comments map[string]*comment
+
+func (c comments) get(path []string) *comment {
+	if result, ok := c[commentPath(path)]; ok {
+		return result
+	}
+	return &comment{}
+}
+
+func (c comments) add(path []string, head string, line string, foot string) {
+	if head == "" && line == "" && foot == "" {
+		return
+	}
+	key := commentPath(path)
+	existing, ok := c[key]
+	if !ok {
+		existing = &comment{}
+		c[key] = existing
+	}
+	existing.Head = joinComments(existing.Head, head)
+	existing.Line = joinComments(existing.Line, line)
+	existing.Foot = joinComments(existing.Foot, foot)
+}
+
+func joinComments(a string, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "\n" + b
+}
+
+// commentPath returns the key of a path of object keys and array item identities
+func commentPath(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// itemIdentity identifies an item of an array independently of its index,
+// so comments stay with their dependency when other dependencies are added or removed
+func itemIdentity(item *jsonNode, index int) string {
+	if item.Kind == "object" {
+		for _, key := range []string{"library", "git", "zip"} {
+			if value := item.stringField(key); value != "" {
+				return fmt.Sprintf("[%s=%s]", key, value)
+			}
+		}
+	} else if item.Kind != "array" {
+		return fmt.Sprintf("[=%v]", item.Value)
+	}
+	return fmt.Sprintf("[%d]", index)
+}
+
+// MarshalProjectDetails returns the project file of the project in the given format. If the previous version
+// of the project file (in any format) is given, keys keep their order, unknown keys are retained and comments are kept.
+// In apm.json only the changed values are rewritten, unchanged values keep their exact bytes. YAML and TOML project
+// files are written as a whole with the style of their values (e.g. quotes, inline tables), YAML project files
+// with their indentation.
+func MarshalProjectDetails(details *ProjectDetails, format string, previous *Document) ([]byte, error) {
+	fileData, err := json.MarshalIndent(details, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatJson && previous == nil {
+		return fileData, nil
+	}
+	document, err := parseJsonDocument(ProjectDetailsFileName, fileData)
+	if err != nil {
+		return nil, err
+	}
+	root := document.root
+	m := newMerger()
+	previousComments := comments{}
+	if previous != nil {
+		m.replaced = previous.replaced
+		root = m.merge(previous.root, document.root, reflect.TypeOf(details))
+		// a migrated project file keeps the layout and the comments of the file
+		if previous.original != nil {
+			previous = previous.original
+		}
+		if format == FormatJson {
+			return writeJson(previous, root, m), nil
+		}
+		previousComments, err = previous.comments()
+		if err != nil {
+			return nil, err
+		}
+		if previous.Format != format {
+			// the indentation of another format is not used
+			previous = nil
+		}
+	}
+	if format == FormatYaml {
+		return marshalYaml(root, previousComments, m, previous), nil
+	}
+	return marshalToml(root, previousComments, m, previous), nil
+}
+
+// ConvertProjectDetails writes the project file in another format and removes the project file in the previous format,
+// comments are kept. The path of the new project file is returned.
+func ConvertProjectDetails(details *ProjectDetails, format string) (string, error) {
+	fileName, err := FormatFileName(format)
+	if err != nil {
+		return "", err
+	}
+	format = strings.ToLower(format)
+	previous := details.document
+	if previous == nil {
+		return "", errors.New("the project file is not loaded")
+	}
+	if previous.Format == format {
+		return "", errors.New(fmt.Sprintf("'%s' is already in %s format", previous.File, format))
+	}
+	previous.logMigration()
+	fileData, err := MarshalProjectDetails(details, format, previous)
+	if err != nil {
+		return "", err
+	}
+	filePath := fmt.Sprintf("%s/%s", details.Dir, fileName)
+	err = util.WriteFileAtomic(filePath, fileData, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+	return filePath, os.Remove(previous.File)
+}
+
+// comments returns the comments of the project file
+func (d *Document) comments() (comments, error) {
+	switch d.Format {
+	case FormatYaml:
+		return yamlComments(d.data)
+	case FormatToml:
+		return tomlComments(d)
+	}
+	return comments{}, nil
+}
+
+// nodeValue returns the value of a node as it is decoded by encoding/json
+func nodeValue(node *jsonNode) interface{} {
+	switch node.Kind {
+	case "object":
+		result := make(map[string]interface{})
+		for _, field := range node.Fields {
+			result[field.Key] = nodeValue(field.Value)
+		}
+		return result
+	case "array":
+		result := make([]interface{}, 0, len(node.Items))
+		for _, item := range node.Items {
+			result = append(result, nodeValue(item))
+		}
+		return result
+	}
+	return node.Value
+}
+
+// toJson returns the document as JSON
+func (d *Document) toJson() ([]byte, error) {
+	if d.Format == FormatJson && d.original == nil {
+		return d.data, nil
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(nodeValue(d.root))
+	return buffer.Bytes(), err
+}
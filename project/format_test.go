@@ -0,0 +1,250 @@
+package project
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// projectCommand returns a command of a project directory like the commands of apm
+func projectCommand(dir string) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("project-dir", dir, "")
+	cmd.Flags().String("env", "", "")
+	return cmd
+}
+
+// rewriteProjectFile reads the project file of a command, changes its project and writes it like 'apm add' does
+func rewriteProjectFile(t *testing.T, cmd *cobra.Command, change func(details *ProjectDetails)) {
+	details, err := GetProjectDetails(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(details)
+	if err := UpdateProjectDetails(cmd, details); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRewriteProjectFileRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		input string
+		added string
+	}{
+		{
+			name: "yaml with 4 spaces and indented items",
+			file: "apm.yaml",
+			input: `# project of the weather station
+
+schema_version: 2
+board:
+    package: "arduino"
+    architecture: avr # the chip of the board
+    version: 'latest'
+    board_manager_urls: ["https://example.com/package_index.json"]
+    board: uno
+
+# pinned because of the servo timer conflict
+dependencies:
+  # the first one
+  - library: Servo
+    version: "1.8.3"
+  - library: 'Ethernet'
+    version: "2.0"
+x-owner: firmware team
+`,
+			added: `# project of the weather station
+
+schema_version: 2
+board:
+    package: "arduino"
+    architecture: avr # the chip of the board
+    version: 'latest'
+    board_manager_urls: ["https://example.com/package_index.json"]
+    board: uno
+
+# pinned because of the servo timer conflict
+dependencies:
+  # the first one
+  - library: Servo
+    version: "1.8.3"
+  - library: 'Ethernet'
+    version: "2.0"
+  - library: DHT sensor library
+    version: "1.0"
+x-owner: firmware team
+`,
+		},
+		{
+			name: "yaml with 2 spaces and items under their key",
+			file: "apm.yml",
+			input: `schema_version: 2
+board:
+  package: esp8266
+  architecture: esp8266
+  version: 3.0.2
+  board: nodemcuv2
+dependencies:
+- library: Servo # keep it
+  version: 1.8.3
+`,
+			added: `schema_version: 2
+board:
+  package: esp8266
+  architecture: esp8266
+  version: 3.0.2
+  board: nodemcuv2
+dependencies:
+- library: Servo # keep it
+  version: 1.8.3
+- library: DHT sensor library
+  version: "1.0"
+`,
+		},
+		{
+			name: "toml with an inline array",
+			file: "apm.toml",
+			input: `# project of the weather station
+
+schema_version = 2
+dependencies = [
+  { library = "Servo", version = "1.8.3" },
+]
+x-notes = """
+first line
+second line
+"""
+
+[board]
+package = 'arduino' # the package
+architecture = "avr"
+version = "latest"
+board = "uno"
+`,
+			added: `# project of the weather station
+
+schema_version = 2
+dependencies = [
+  { library = "Servo", version = "1.8.3" },
+  { library = "DHT sensor library", version = "1.0" },
+]
+x-notes = """
+first line
+second line
+"""
+
+[board]
+package = 'arduino' # the package
+architecture = "avr"
+version = "latest"
+board = "uno"
+`,
+		},
+		{
+			name: "toml with tables",
+			file: "apm.toml",
+			input: `schema_version = 2
+
+[board]
+package = "arduino"
+architecture = "avr"
+version = "latest"
+board = "uno"
+board_manager_urls = [
+    "https://example.com/package_index.json",
+    "https://example.com/other_index.json"
+]
+
+# pinned
+[[dependencies]]
+library = "Servo"
+version = "1.8.3"
+`,
+			added: `schema_version = 2
+
+[board]
+package = "arduino"
+architecture = "avr"
+version = "latest"
+board = "uno"
+board_manager_urls = [
+    "https://example.com/package_index.json",
+    "https://example.com/other_index.json"
+]
+
+# pinned
+[[dependencies]]
+library = "Servo"
+version = "1.8.3"
+
+[[dependencies]]
+library = "DHT sensor library"
+version = "1.0"
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "apm-format-test-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, test.file)
+			if err := ioutil.WriteFile(file, []byte(test.input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := projectCommand(dir)
+
+			rewriteProjectFile(t, cmd, func(details *ProjectDetails) {
+				details.Dependencies = append(details.Dependencies, ProjectDependency{Library: "DHT sensor library", Version: "1.0"})
+			})
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.added {
+				t.Errorf("after adding:\n%s\nwant:\n%s", data, test.added)
+			}
+
+			rewriteProjectFile(t, cmd, func(details *ProjectDetails) {
+				details.Dependencies = details.Dependencies[:len(details.Dependencies)-1]
+			})
+			data, err = ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.input {
+				t.Errorf("after removing:\n%s\nwant:\n%s", data, test.input)
+			}
+		})
+	}
+}
+
+func TestMarshalYamlQuotesStrings(t *testing.T) {
+	details := &ProjectDetails{Dependencies: []ProjectDependency{
+		{Library: "Servo", Version: "1.0"},
+		{Library: "true", Version: "1.8.3"},
+		{Library: "a: b", Version: ""},
+	}}
+	data, err := MarshalProjectDetails(details, FormatYaml, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `board: null
+dependencies:
+- library: Servo
+  version: "1.0"
+- library: "true"
+  version: 1.8.3
+- library: 'a: b'
+`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+}
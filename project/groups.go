@@ -0,0 +1,144 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// DevGroup is the name of the dependency group of dev_dependencies
+var DevGroup = "dev"
+
+// GetDependencyGroups returns the dependency groups of the project selected with --with and --without:
+// every group which is not optional, without the ones of --without, and the optional ones of --with
+func GetDependencyGroups(cmd *cobra.Command, details *ProjectDetails) ([]string, error) {
+	with, err := cmd.Flags().GetStringSlice("with")
+	if err != nil {
+		return nil, err
+	}
+	without, err := cmd.Flags().GetStringSlice("without")
+	if err != nil {
+		return nil, err
+	}
+	return details.SelectGroups(with, without)
+}
+
+// GroupNames returns the names of the dependency groups in alphabetical order, dev_dependencies are the "dev" group
+func (d *ProjectDetails) GroupNames() []string {
+	var result []string
+	for name := range d.Groups {
+		result = append(result, name)
+	}
+	if _, ok := d.Groups[DevGroup]; !ok && len(d.DevDependencies) > 0 {
+		result = append(result, DevGroup)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// group returns a dependency group of the project, dev_dependencies are the "dev" group
+func (d *ProjectDetails) group(name string) *DependencyGroup {
+	if group, ok := d.Groups[name]; ok && group != nil {
+		return group
+	}
+	if name == DevGroup && len(d.DevDependencies) > 0 {
+		return &DependencyGroup{Dependencies: d.DevDependencies}
+	}
+	return nil
+}
+
+// SelectGroups returns the dependency groups to install: every group which is not optional, without the excluded ones,
+// and the optional ones which are included
+func (d *ProjectDetails) SelectGroups(with []string, without []string) ([]string, error) {
+	names := d.GroupNames()
+	for _, name := range append(append([]string{}, with...), without...) {
+		if !containsString(names, name) {
+			if len(names) == 0 {
+				return nil, errors.New(fmt.Sprintf("dependency group '%s' not found, the project has no dependency groups", name))
+			}
+			return nil, errors.New(fmt.Sprintf("dependency group '%s' not found, available groups: %s", name, strings.Join(names, ", ")))
+		}
+		if containsString(with, name) && containsString(without, name) {
+			return nil, errors.New(fmt.Sprintf("dependency group '%s' can not be used with --with and --without", name))
+		}
+	}
+	result := []string{}
+	for _, name := range names {
+		if containsString(without, name) || (d.IsOptionalGroup(name) && !containsString(with, name)) {
+			continue
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// WithGroups returns the project with the dependencies of the given groups added to its dependencies,
+// a library which is already a dependency of the project is not added again
+func (d *ProjectDetails) WithGroups(names []string) *ProjectDetails {
+	result := *d
+	result.Dependencies = append([]ProjectDependency{}, d.Dependencies...)
+	for _, name := range names {
+		group := d.group(name)
+		if group == nil {
+			continue
+		}
+		for _, dep := range group.Dependencies {
+			if !containsDependency(result.Dependencies, dep) {
+				result.Dependencies = append(result.Dependencies, dep)
+			}
+		}
+	}
+	result.SelectedGroups = names
+	return &result
+}
+
+// ExcludedDependencies returns the dependencies of the groups which were not added to the dependencies of the project
+func (d *ProjectDetails) ExcludedDependencies() []ProjectDependency {
+	var result []ProjectDependency
+	for _, name := range d.GroupNames() {
+		group := d.group(name)
+		if group == nil || containsString(d.SelectedGroups, name) {
+			continue
+		}
+		for _, dep := range group.Dependencies {
+			if !containsDependency(d.Dependencies, dep) && !containsDependency(result, dep) {
+				result = append(result, dep)
+			}
+		}
+	}
+	return result
+}
+
+// GroupDependenciesOf returns the dependency list of a group to be edited, dev_dependencies are the "dev" group.
+// The group is added to the project if it does not exist yet.
+func (d *ProjectDetails) GroupDependenciesOf(name string) *[]ProjectDependency {
+	if group, ok := d.Groups[name]; ok && group != nil {
+		return &group.Dependencies
+	}
+	if name == DevGroup {
+		return &d.DevDependencies
+	}
+	if d.Groups == nil {
+		d.Groups = make(map[string]*DependencyGroup)
+	}
+	d.Groups[name] = &DependencyGroup{Dependencies: []ProjectDependency{}}
+	return &d.Groups[name].Dependencies
+}
+
+// IsOptionalGroup returns true if the dependency group is only installed with --with
+func (d *ProjectDetails) IsOptionalGroup(name string) bool {
+	group := d.group(name)
+	return group != nil && group.Optional
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ksrichard/apm/util"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+var ProjectLockFileName string = "apm.lock"
+
+// LockFileName returns the name of the lock file of an environment (apm.<env>.lock) or apm.lock for an empty name
+func LockFileName(env string) string {
+	if env == "" {
+		return ProjectLockFileName
+	}
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(ProjectLockFileName, ".lock"), env, ".lock")
+}
+
+// GetProjectLock returns the lock file of the project (or of the environment selected with --env)
+// or an empty lock if it does not exist yet
+func GetProjectLock(cmd *cobra.Command) (*ProjectLock, error) {
+	lockFilePath, err := GetProjectLockFile(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return ReadProjectLock(lockFilePath)
+}
+
+// ReadProjectLock reads a lock file or returns an empty lock if it does not exist
+func ReadProjectLock(lockFilePath string) (*ProjectLock, error) {
+	result := ProjectLock{}
+	if util.FileExists(lockFilePath) {
+		lockFile, err := ioutil.ReadFile(lockFilePath)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(lockFile, &result)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid '%s': %s", lockFilePath, err))
+		}
+	}
+	return &result, nil
+}
+
+func UpdateProjectLock(cmd *cobra.Command, lock *ProjectLock) error {
+	lockFilePath, err := GetProjectLockFile(cmd)
+	if err != nil {
+		return err
+	}
+	fileData, err := json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(lockFilePath, fileData, os.ModePerm)
+}
+
+// GetProjectLockFile returns the path of the lock file of the environment selected with --env
+func GetProjectLockFile(cmd *cobra.Command) (string, error) {
+	projectDir, err := GetProjectDir(cmd)
+	if err != nil {
+		return "", err
+	}
+	env, err := GetEnvironmentName(cmd)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", projectDir, LockFileName(env)), nil
+}
+
+// Library returns the locked entry of an index library
+func (l *ProjectLock) Library(name string) *LockedDependency {
+	for i, dep := range l.Dependencies {
+		if dep.Library != "" && strings.ToLower(dep.Library) == strings.ToLower(name) {
+			return &l.Dependencies[i]
+		}
+	}
+	return nil
+}
+
+// Locked returns the lock entry of a project dependency if it is still compatible with it
+func (l *ProjectLock) Locked(dep ProjectDependency) *LockedDependency {
+	for i, locked := range l.Dependencies {
+		if dep.Library != "" && strings.ToLower(locked.Library) == strings.ToLower(dep.Library) &&
+			LockedVersionSatisfies(dep.Version, locked.Version) {
+			return &l.Dependencies[i]
+		}
+		if dep.Git != "" && locked.Git == dep.Git && locked.Ref == dep.Ref && locked.Commit != "" &&
+			(dep.Sha256 == "" || dep.Sha256 == locked.Hash) {
+			return &l.Dependencies[i]
+		}
+		if dep.Zip != "" && locked.Zip == dep.Zip {
+			return &l.Dependencies[i]
+		}
+	}
+	return nil
+}
+
+// LockedDependencies returns the locked entries needed by the project (including library dependencies).
+// The second return value is false if any of them is missing or no longer compatible with apm.json.
+func (l *ProjectLock) LockedDependencies(details *ProjectDetails) ([]LockedDependency, bool) {
+	result := []LockedDependency{}
+	var pending []string
+	for _, dep := range details.Dependencies {
+		locked := l.Locked(dep)
+		if locked == nil {
+			return nil, false
+		}
+		if locked.Library == "" {
+			result = append(result, *locked)
+		} else {
+			pending = append(pending, locked.Library)
+		}
+	}
+
+	// walk library dependencies
+	seen := make(map[string]bool)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		locked := l.Library(name)
+		if locked == nil {
+			return nil, false
+		}
+		result = append(result, *locked)
+		pending = append(pending, locked.Dependencies...)
+	}
+	return result, true
+}
+
+// LockedPlatform returns the lock entry of an additional platform of the board if it is still compatible with apm.json
+func (l *ProjectLock) LockedPlatform(platform ProjectPlatform) *LockedBoard {
+	for i, locked := range l.Platforms {
+		if locked.Package == platform.Package && locked.Architecture == platform.Architecture &&
+			LockedVersionSatisfies(platform.Version, locked.Version) {
+			return &l.Platforms[i]
+		}
+	}
+	return nil
+}
+
+// PlatformsLocked returns true if every additional platform of the board is locked
+func (l *ProjectLock) PlatformsLocked(board *ProjectBoard) bool {
+	for _, platform := range board.Platforms {
+		if l.LockedPlatform(platform) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// KeepLocked adds the entries of the given dependencies (including library dependencies) locked in the previous lock
+// if they are not locked anymore, so the dependency groups which were not installed stay locked
+func (l *ProjectLock) KeepLocked(previous []LockedDependency, deps []ProjectDependency) {
+	previousLock := &ProjectLock{Dependencies: previous}
+	for _, dep := range deps {
+		lockedDeps, ok := previousLock.LockedDependencies(&ProjectDetails{Dependencies: []ProjectDependency{dep}})
+		if !ok {
+			continue
+		}
+		for _, locked := range lockedDeps {
+			if !l.hasLocked(locked) {
+				l.Dependencies = append(l.Dependencies, locked)
+			}
+		}
+	}
+}
+
+// hasLocked returns true if the library, git repository or zip file of a locked entry is locked
+func (l *ProjectLock) hasLocked(locked LockedDependency) bool {
+	for _, dep := range l.Dependencies {
+		if (locked.Library != "" && strings.ToLower(dep.Library) == strings.ToLower(locked.Library)) ||
+			(locked.Git != "" && dep.Git == locked.Git) ||
+			(locked.Zip != "" && dep.Zip == locked.Zip) {
+			return true
+		}
+	}
+	return false
+}
+
+// BoardLocked returns true if the locked board core is still compatible with the one in apm.json
+func (l *ProjectLock) BoardLocked(board *ProjectBoard) bool {
+	return l.Board != nil && board != nil &&
+		l.Board.Package == board.Package &&
+		l.Board.Architecture == board.Architecture &&
+		LockedVersionSatisfies(board.Version, l.Board.Version)
+}
+
+// Unlock removes the locked entry of an index library so it is resolved again on the next install
+func (l *ProjectLock) Unlock(name string) {
+	for i, dep := range l.Dependencies {
+		if dep.Library != "" && strings.ToLower(dep.Library) == strings.ToLower(name) {
+			l.Dependencies = append(l.Dependencies[:i], l.Dependencies[i+1:]...)
+			return
+		}
+	}
+}
+
+// UnlockGit removes the locked commit of a git dependency so its ref is resolved again on the next install
+func (l *ProjectLock) UnlockGit(gitUrl string) {
+	for i, dep := range l.Dependencies {
+		if dep.Git == gitUrl {
+			l.Dependencies = append(l.Dependencies[:i], l.Dependencies[i+1:]...)
+			return
+		}
+	}
+}
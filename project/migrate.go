@@ -0,0 +1,254 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// CurrentSchemaVersion is the schema version of the project files written by this version of apm
+var CurrentSchemaVersion = 2
+
+// Migration upgrades a project file from a schema version to the next one
+type Migration struct {
+	From        int
+	Description string
+	// apply changes the project file and returns the descriptions of the changes
+	apply func(d *Document, m *migrator) []string
+}
+
+// Migrations are the migrations of the project file in the order of the schema versions
+var Migrations = []Migration{
+	{From: 1, Description: "board_manager_url of the boards is moved to board_manager_urls", apply: moveBoardManagerUrls},
+}
+
+// SchemaVersion returns the schema version of the project file, project files without schema_version have version 1
+func (d *Document) SchemaVersion() (int, error) {
+	node := d.root.field("schema_version")
+	if node == nil {
+		return 1, nil
+	}
+	if node.Kind == "number" {
+		if version, err := strconv.Atoi(fmt.Sprint(node.Value)); err == nil && version >= 1 {
+			return version, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("%s: schema_version must be a positive integer", d.linePosition(node.Line, node.Column)))
+}
+
+// PendingMigrations returns the migrations of a project file of the given schema version
+func PendingMigrations(version int) []Migration {
+	var result []Migration
+	for _, migration := range Migrations {
+		if migration.From >= version {
+			result = append(result, migration)
+		}
+	}
+	return result
+}
+
+// Migrate returns the project file migrated to the current schema version step by step with the descriptions of the changes,
+// the document itself is returned if it has the current schema version. The project file on the disk is not changed.
+func (d *Document) Migrate() (*Document, []string, error) {
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return nil, nil, err
+	}
+	if version > CurrentSchemaVersion {
+		return nil, nil, errors.New(fmt.Sprintf("'%s' has schema version %d, but this version of apm supports up to schema version %d, please upgrade apm", d.File, version, CurrentSchemaVersion))
+	}
+	// invalid project files are reported by the validation
+	if version == CurrentSchemaVersion || d.root == nil || d.root.Kind != "object" {
+		return d, nil, nil
+	}
+
+	m := &migrator{root: d.root, replaced: make(map[*jsonNode]*jsonNode)}
+	var changes []string
+	for _, migration := range PendingMigrations(version) {
+		changes = append(changes, migration.apply(d, m)...)
+	}
+
+	// schema_version is written after $schema or as the first key
+	root := m.edit()
+	index := removeField(root, "schema_version")
+	if index < 0 {
+		index = 0
+		for i, field := range root.Fields {
+			if field.Key == "$schema" {
+				index = i + 1
+			}
+		}
+	}
+	insertField(root, index, "schema_version", m.add(&jsonNode{Kind: "number", Value: json.Number(strconv.Itoa(CurrentSchemaVersion))}))
+	changes = append(changes, fmt.Sprintf("schema_version is set to %d", CurrentSchemaVersion))
+
+	result := &Document{File: d.File, Dir: d.Dir, Format: d.Format, data: d.data, root: m.root, original: d, replaced: m.replaced, changes: changes}
+	return result, changes, nil
+}
+
+// MigrateProjectFile writes a project file migrated by Migrate, it fails with all problems found by the validation
+func MigrateProjectFile(cmd *cobra.Command, migrated *Document) error {
+	if problems := migrated.Validate(); len(problems) > 0 {
+		return errors.New(fmt.Sprintf("invalid '%s' after the migration, please fix it first:\n%s", migrated.File, FormatProblems(problems)))
+	}
+	details, err := migrated.Details()
+	if err != nil {
+		return err
+	}
+	return writeProjectDetails(cmd, details)
+}
+
+// logMigration prints the changes of the migration of a project file which is written by a command changing it
+func (d *Document) logMigration() {
+	if d == nil || d.original == nil {
+		return
+	}
+	version, _ := d.original.SchemaVersion()
+	log.Printf("Migrating '%s' from schema version %d to %d:\n", d.File, version, CurrentSchemaVersion)
+	for _, change := range d.changes {
+		log.Printf("  %s\n", change)
+	}
+}
+
+// migrator changes the nodes of a project file, the nodes of the project file are not changed: changed nodes are
+// copies which remember the node they replace
+type migrator struct {
+	root     *jsonNode
+	replaced map[*jsonNode]*jsonNode
+}
+
+// edit returns a changeable copy of the object or array at a path of object keys or nil if it does not exist
+func (m *migrator) edit(path ...string) *jsonNode {
+	if _, ok := m.replaced[m.root]; !ok {
+		m.root = m.copy(m.root)
+	}
+	node := m.root
+	for _, key := range path {
+		var field *jsonField
+		// the last one wins like in encoding/json
+		for _, f := range node.Fields {
+			if f.Key == key {
+				field = f
+			}
+		}
+		if field == nil || (field.Value.Kind != "object" && field.Value.Kind != "array") {
+			return nil
+		}
+		if _, ok := m.replaced[field.Value]; !ok {
+			field.Value = m.copy(field.Value)
+		}
+		node = field.Value
+	}
+	return node
+}
+
+// copy returns a copy of a node which replaces it
+func (m *migrator) copy(node *jsonNode) *jsonNode {
+	result := *node
+	result.Fields = nil
+	for _, field := range node.Fields {
+		copied := *field
+		result.Fields = append(result.Fields, &copied)
+	}
+	result.Items = append([]*jsonNode{}, node.Items...)
+	m.replaced[&result] = node
+	return &result
+}
+
+// add registers a new node of the migrated project file
+func (m *migrator) add(node *jsonNode) *jsonNode {
+	m.replaced[node] = nil
+	return node
+}
+
+// lookup returns the node at a path of object keys or nil if it does not exist
+func (m *migrator) lookup(path ...string) *jsonNode {
+	node := m.root
+	for _, key := range path {
+		node = node.field(key)
+	}
+	return node
+}
+
+// removeField removes a key of an object and returns its index or -1 if it is not set
+func removeField(node *jsonNode, key string) int {
+	index := -1
+	var fields []*jsonField
+	for _, field := range node.Fields {
+		if field.Key == key {
+			if index < 0 {
+				index = len(fields)
+			}
+			continue
+		}
+		fields = append(fields, field)
+	}
+	node.Fields = fields
+	return index
+}
+
+// insertField inserts a key into an object at the given index
+func insertField(node *jsonNode, index int, key string, value *jsonNode) {
+	field := &jsonField{Key: key, KeyLine: value.Line, KeyColumn: value.Column, Value: value}
+	node.Fields = append(node.Fields, nil)
+	copy(node.Fields[index+1:], node.Fields[index:])
+	node.Fields[index] = field
+}
+
+// moveBoardManagerUrls moves board_manager_url of the board and of the boards of the environments to the beginning
+// of board_manager_urls (schema version 1 to 2)
+func moveBoardManagerUrls(d *Document, m *migrator) []string {
+	paths := [][]string{{"board"}}
+	if environments := m.lookup("environments"); environments != nil && environments.Kind == "object" {
+		for _, field := range environments.Fields {
+			paths = append(paths, []string{"environments", field.Key, "board"})
+		}
+	}
+	var result []string
+	for _, path := range paths {
+		board := m.lookup(path...)
+		if board == nil || board.Kind != "object" {
+			continue
+		}
+		url := board.field("board_manager_url")
+		urls := board.field("board_manager_urls")
+		if url == nil || url.Kind != "string" || (urls != nil && urls.Kind != "array") {
+			// invalid values are reported by the validation
+			continue
+		}
+		board = m.edit(path...)
+		index := removeField(board, "board_manager_url")
+		name := strings.Join(path, ".")
+		if url.Value.(string) == "" || containsUrl(urls, url.Value.(string)) {
+			result = append(result, fmt.Sprintf("%s: %s.board_manager_url is removed", d.linePosition(url.Line, url.Column), name))
+			continue
+		}
+		if urls == nil {
+			urls = m.add(&jsonNode{Kind: "array", Line: url.Line, Column: url.Column})
+			insertField(board, index, "board_manager_urls", urls)
+		} else {
+			urls = m.edit(append(append([]string{}, path...), "board_manager_urls")...)
+		}
+		urls.Items = append([]*jsonNode{url}, urls.Items...)
+		result = append(result, fmt.Sprintf("%s: %s.board_manager_url is moved to %s.board_manager_urls", d.linePosition(url.Line, url.Column), name, name))
+	}
+	return result
+}
+
+// containsUrl returns true if an array node contains the URL
+func containsUrl(urls *jsonNode, url string) bool {
+	if urls == nil {
+		return false
+	}
+	for _, item := range urls.Items {
+		if item.Kind == "string" && strings.TrimSpace(item.Value.(string)) == strings.TrimSpace(url) {
+			return true
+		}
+	}
+	return false
+}
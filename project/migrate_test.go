@@ -0,0 +1,105 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		changes []string
+	}{
+		{
+			name:  "board_manager_url is moved to board_manager_urls",
+			input: "{\n  \"board\": {\n    \"package\": \"esp8266\",\n    \"architecture\": \"esp8266\",\n    \"version\": \"3.0.2\",\n    \"board_manager_url\": \"https://example.com/package_esp8266_index.json\",\n    \"board\": \"nodemcuv2\"\n  }\n}\n",
+			want:  "{\n  \"schema_version\": 2,\n  \"board\": {\n    \"package\": \"esp8266\",\n    \"architecture\": \"esp8266\",\n    \"version\": \"3.0.2\",\n    \"board_manager_urls\": [\n      \"https://example.com/package_esp8266_index.json\"\n    ],\n    \"board\": \"nodemcuv2\"\n  }\n}\n",
+			changes: []string{
+				"apm.json:6:26: board.board_manager_url is moved to board.board_manager_urls",
+				"schema_version is set to 2",
+			},
+		},
+		{
+			name:  "board_manager_url is added before board_manager_urls",
+			input: "{\n  \"$schema\": \"apm.schema.json\",\n  \"board\": {\n    \"board_manager_url\": \"https://example.com/a.json\",\n    \"board_manager_urls\": [\"https://example.com/b.json\"]\n  }\n}\n",
+			want:  "{\n  \"$schema\": \"apm.schema.json\",\n  \"schema_version\": 2,\n  \"board\": {\n    \"board_manager_urls\": [\"https://example.com/a.json\", \"https://example.com/b.json\"]\n  }\n}\n",
+			changes: []string{
+				"apm.json:4:26: board.board_manager_url is moved to board.board_manager_urls",
+				"schema_version is set to 2",
+			},
+		},
+		{
+			name:  "board_manager_url already in board_manager_urls is removed",
+			input: "{\n  \"schema_version\": 1,\n  \"board\": {\n    \"board_manager_url\": \"https://example.com/a.json\",\n    \"board_manager_urls\": [\"https://example.com/a.json\"]\n  }\n}\n",
+			want:  "{\n  \"schema_version\": 2,\n  \"board\": {\n    \"board_manager_urls\": [\"https://example.com/a.json\"]\n  }\n}\n",
+			changes: []string{
+				"apm.json:4:26: board.board_manager_url is removed",
+				"schema_version is set to 2",
+			},
+		},
+		{
+			name:  "boards of the environments",
+			input: "{\n  \"board\": null,\n  \"environments\": {\n    \"esp\": {\"board\": {\"board_manager_url\": \"https://example.com/a.json\"}}\n  }\n}\n",
+			want:  "{\n  \"schema_version\": 2,\n  \"board\": null,\n  \"environments\": {\n    \"esp\": {\"board\": {\"board_manager_urls\": [\"https://example.com/a.json\"]}}\n  }\n}\n",
+			changes: []string{
+				"apm.json:4:44: environments.esp.board.board_manager_url is moved to environments.esp.board.board_manager_urls",
+				"schema_version is set to 2",
+			},
+		},
+		{
+			name:  "current schema version",
+			input: "{\n  \"schema_version\": 2,\n  \"board\": null\n}\n",
+			want:  "{\n  \"schema_version\": 2,\n  \"board\": null\n}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, err := ParseDocument(ProjectDetailsFileName, []byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			migrated, changes, err := document.Migrate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, test.changes) {
+				t.Errorf("changes %q, want %q", changes, test.changes)
+			}
+			if problems := migrated.Validate(); len(problems) > 0 {
+				t.Fatalf("invalid after the migration:\n%s", FormatProblems(problems))
+			}
+			details, err := migrated.Details()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := MarshalProjectDetails(details, FormatJson, migrated)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("got:\n%q\nwant:\n%q", data, test.want)
+			}
+		})
+	}
+}
+
+func TestMigrateNewerSchemaVersion(t *testing.T) {
+	document, err := ParseDocument(ProjectDetailsFileName, []byte(`{"schema_version": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := document.Migrate(); err == nil {
+		t.Errorf("no error for a newer schema version")
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	if got := len(PendingMigrations(1)); got != 1 {
+		t.Errorf("%d migrations from schema version 1, want 1", got)
+	}
+	if got := len(PendingMigrations(CurrentSchemaVersion)); got != 0 {
+		t.Errorf("%d migrations from the current schema version, want 0", got)
+	}
+}
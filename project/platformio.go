@@ -0,0 +1,445 @@
+package project
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ksrichard/apm/util"
+)
+
+// PlatformioFileName is the project file of PlatformIO projects
+var PlatformioFileName = "platformio.ini"
+
+var platformioInterpolationRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// platformioPlatform is the Arduino core package of a PlatformIO platform
+type platformioPlatform struct {
+	Package         string
+	Architecture    string
+	BoardManagerUrl string
+}
+
+var platformioPlatforms = map[string]platformioPlatform{
+	"atmelavr":      {Package: "arduino", Architecture: "avr"},
+	"atmelmegaavr":  {Package: "arduino", Architecture: "megaavr"},
+	"atmelsam":      {Package: "arduino", Architecture: "samd"},
+	"espressif8266": {Package: "esp8266", Architecture: "esp8266", BoardManagerUrl: "https://arduino.esp8266.com/stable/package_esp8266com_index.json"},
+	"espressif32":   {Package: "esp32", Architecture: "esp32", BoardManagerUrl: "https://raw.githubusercontent.com/espressif/arduino-esp32/gh-pages/package_esp32_index.json"},
+}
+
+// platformioBoard is the board ID and the board options in the Arduino core package of a PlatformIO board
+type platformioBoard struct {
+	Board   string
+	Options map[string]string
+	// the board is in another core package than the other boards of its platform (e.g. the Arduino Due)
+	Architecture string
+}
+
+var platformioBoards = map[string]platformioBoard{
+	"megaatmega2560":    {Board: "mega", Options: map[string]string{"cpu": "atmega2560"}},
+	"megaatmega1280":    {Board: "mega", Options: map[string]string{"cpu": "atmega1280"}},
+	"nanoatmega328":     {Board: "nano", Options: map[string]string{"cpu": "atmega328old"}},
+	"nanoatmega328new":  {Board: "nano", Options: map[string]string{"cpu": "atmega328"}},
+	"pro16MHzatmega328": {Board: "pro", Options: map[string]string{"cpu": "16MHzatmega328"}},
+	"pro8MHzatmega328":  {Board: "pro", Options: map[string]string{"cpu": "8MHzatmega328"}},
+	"esp32dev":          {Board: "esp32"},
+	"zero":              {Board: "arduino_zero_edbg"},
+	"mkr1000USB":        {Board: "mkr1000"},
+	"due":               {Board: "arduino_due_x_dbg", Architecture: "sam"},
+	"dueUSB":            {Board: "arduino_due_x", Architecture: "sam"},
+}
+
+// platformioIni is a parsed platformio.ini
+type platformioIni struct {
+	// sections in the order of the file
+	sections []string
+	values   map[string]map[string]string
+}
+
+// ImportPlatformio converts the environments of a platformio.ini (a file or the directory of a PlatformIO project)
+// into the project details of apm. Everything which can not be translated is returned as a list of messages.
+// Zip dependencies are written relative to projectDir.
+func ImportPlatformio(path string, projectDir string) (*ProjectDetails, []string, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, PlatformioFileName)
+	}
+	ini, err := parsePlatformioIni(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	importer := &platformioImporter{ini: ini, dir: filepath.Dir(path), projectDir: projectDir}
+	details, err := importer.details()
+	if err != nil {
+		return nil, nil, err
+	}
+	return details, importer.untranslated, nil
+}
+
+func parsePlatformioIni(path string) (*platformioIni, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	result := &platformioIni{values: make(map[string]map[string]string)}
+	section := ""
+	key := ""
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, ";") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		// inline comments start with " ;"
+		if i := strings.Index(trimmed, " ;"); i >= 0 {
+			trimmed = strings.TrimSpace(trimmed[:i])
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			key = ""
+			if _, ok := result.values[section]; !ok {
+				result.sections = append(result.sections, section)
+				result.values[section] = make(map[string]string)
+			}
+		case (line[0] == ' ' || line[0] == '\t') && key != "":
+			// indented lines continue the value of the previous key
+			result.values[section][key] += "\n" + trimmed
+		default:
+			equals := strings.Index(trimmed, "=")
+			if equals < 0 || section == "" {
+				return nil, errors.New(fmt.Sprintf("%s:%d: invalid line '%s'", path, lineNumber, trimmed))
+			}
+			key = strings.TrimSpace(trimmed[:equals])
+			result.values[section][key] = strings.TrimSpace(trimmed[equals+1:])
+		}
+	}
+	return result, scanner.Err()
+}
+
+// get returns the value of a key in a section following 'extends' and the common [env] section, references to other
+// values (${section.key}, ${this.key} and ${sysenv.NAME}) are replaced
+func (p *platformioIni) get(section string, key string) (string, bool) {
+	return p.lookup(section, key, make(map[string]bool))
+}
+
+func (p *platformioIni) lookup(section string, key string, visited map[string]bool) (string, bool) {
+	// references to itself are not resolved
+	id := section + "\x00" + key
+	if visited[id] {
+		return "", false
+	}
+	visited[id] = true
+	defer delete(visited, id)
+	if value, ok := p.values[section][key]; ok {
+		return p.interpolate(section, value, visited), true
+	}
+	if extends, ok := p.values[section]["extends"]; ok && key != "extends" {
+		for _, parent := range strings.Split(extends, ",") {
+			if value, ok := p.lookup(strings.TrimSpace(parent), key, visited); ok {
+				return value, true
+			}
+		}
+	}
+	if strings.HasPrefix(section, "env:") {
+		return p.lookup("env", key, visited)
+	}
+	return "", false
+}
+
+func (p *platformioIni) interpolate(section string, value string, visited map[string]bool) string {
+	return platformioInterpolationRegex.ReplaceAllStringFunc(value, func(reference string) string {
+		name := reference[2 : len(reference)-1]
+		dot := strings.LastIndex(name, ".")
+		if dot < 0 {
+			return reference
+		}
+		refSection, refKey := name[:dot], name[dot+1:]
+		switch refSection {
+		case "sysenv":
+			return os.Getenv(refKey)
+		case "this":
+			refSection = section
+		}
+		if result, ok := p.lookup(refSection, refKey, visited); ok {
+			return result
+		}
+		return reference
+	})
+}
+
+// sectionChain returns the section, the sections it extends and the common [env] section of environments
+func (p *platformioIni) sectionChain(section string, visited map[string]bool) []string {
+	if visited[section] {
+		return nil
+	}
+	visited[section] = true
+	result := []string{section}
+	if extends, ok := p.values[section]["extends"]; ok {
+		for _, parent := range strings.Split(extends, ",") {
+			result = append(result, p.sectionChain(strings.TrimSpace(parent), visited)...)
+		}
+	}
+	if strings.HasPrefix(section, "env:") {
+		result = append(result, p.sectionChain("env", visited)...)
+	}
+	return result
+}
+
+// environments returns the names of the environments, the default environment comes first
+func (p *platformioIni) environments() []string {
+	var result []string
+	for _, section := range p.sections {
+		if strings.HasPrefix(section, "env:") {
+			result = append(result, strings.TrimPrefix(section, "env:"))
+		}
+	}
+	defaults, _ := p.get("platformio", "default_envs")
+	names := strings.FieldsFunc(defaults, func(r rune) bool { return r == ',' || r == '\n' })
+	if len(names) == 0 {
+		return result
+	}
+	sorted := []string{strings.TrimSpace(names[0])}
+	found := false
+	for _, env := range result {
+		if env == sorted[0] {
+			found = true
+		} else {
+			sorted = append(sorted, env)
+		}
+	}
+	if !found {
+		return result
+	}
+	return sorted
+}
+
+type platformioImporter struct {
+	ini *platformioIni
+	// directory of platformio.ini
+	dir string
+	// directory of the apm project
+	projectDir   string
+	untranslated []string
+}
+
+// platformioKeys are the keys of an environment which are translated
+var platformioKeys = map[string]bool{"platform": true, "board": true, "framework": true, "lib_deps": true, "build_flags": true, "extends": true}
+
+func (i *platformioImporter) details() (*ProjectDetails, error) {
+	envs := i.ini.environments()
+	if len(envs) == 0 {
+		return nil, errors.New(fmt.Sprintf("no environment ([env:NAME] section) found in %s", PlatformioFileName))
+	}
+	boards := make(map[string]*ProjectBoard)
+	deps := make(map[string][]ProjectDependency)
+	for _, env := range envs {
+		section := "env:" + env
+		i.reportUntranslatedKeys(env, section)
+		board, err := i.board(env, section)
+		if err != nil {
+			return nil, err
+		}
+		boards[env] = board
+		deps[env] = i.dependencies(env, section)
+	}
+
+	return DetailsOfEnvironments(envs, boards, deps), nil
+}
+
+func (i *platformioImporter) reportUntranslatedKeys(env string, section string) {
+	keys := make(map[string]bool)
+	for _, s := range i.ini.sectionChain(section, make(map[string]bool)) {
+		for key := range i.ini.values[s] {
+			keys[key] = true
+		}
+	}
+	var sorted []string
+	for key := range keys {
+		if !platformioKeys[key] {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		value, _ := i.ini.get(section, key)
+		i.note(env, fmt.Sprintf("%s = %s", key, strings.Replace(value, "\n", ", ", -1)))
+	}
+}
+
+func (i *platformioImporter) note(env string, message string) {
+	i.untranslated = append(i.untranslated, fmt.Sprintf("[env:%s] %s", env, message))
+}
+
+// board maps the platform and board of an environment onto the board of apm
+func (i *platformioImporter) board(env string, section string) (*ProjectBoard, error) {
+	if framework, ok := i.ini.get(section, "framework"); ok && !strings.Contains(framework, "arduino") {
+		i.note(env, fmt.Sprintf("framework = %s is not supported, only the arduino framework can be used", framework))
+	}
+	result := &ProjectBoard{Version: "latest"}
+	platform, _ := i.ini.get(section, "platform")
+	platformName, platformVersion := splitPlatformioVersion(platform)
+	// registry platforms can have an owner: [OWNER/]NAME (e.g. platformio/espressif32)
+	if slash := strings.LastIndex(platformName, "/"); slash >= 0 && !strings.Contains(platformName, "://") {
+		platformName = strings.TrimSpace(platformName[slash+1:])
+	}
+	mapped, ok := platformioPlatforms[platformName]
+	if platform == "" {
+		i.note(env, "no platform is set")
+		return &ProjectBoard{}, nil
+	}
+	if !ok {
+		i.note(env, fmt.Sprintf("platform = %s has no known Arduino core package", platform))
+		return &ProjectBoard{}, nil
+	}
+	if platformVersion != "" {
+		i.note(env, fmt.Sprintf("platform version %s is a version of the PlatformIO platform, the latest Arduino core is used", platformVersion))
+	}
+	result.Package = mapped.Package
+	result.Architecture = mapped.Architecture
+	if mapped.BoardManagerUrl != "" {
+		result.BoardManagerUrls = []string{mapped.BoardManagerUrl}
+	}
+
+	board, _ := i.ini.get(section, "board")
+	if known, ok := platformioBoards[board]; ok {
+		result.Board = known.Board
+		result.Options = known.Options
+		if known.Architecture != "" {
+			result.Architecture = known.Architecture
+		}
+	} else if board != "" {
+		result.Board = board
+	}
+
+	// preprocessor defines of the build flags
+	if flags, ok := i.ini.get(section, "build_flags"); ok {
+		fields := strings.Fields(flags)
+		for j := 0; j < len(fields); j++ {
+			switch {
+			case fields[j] == "-D" && j+1 < len(fields):
+				j++
+				result.Defines = append(result.Defines, fields[j])
+			case strings.HasPrefix(fields[j], "-D") && len(fields[j]) > 2:
+				result.Defines = append(result.Defines, fields[j][2:])
+			default:
+				i.note(env, fmt.Sprintf("build flag %s", fields[j]))
+			}
+		}
+	}
+	return result, nil
+}
+
+// dependencies maps the lib_deps of an environment onto dependencies of apm
+func (i *platformioImporter) dependencies(env string, section string) []ProjectDependency {
+	var result []ProjectDependency
+	libDeps, _ := i.ini.get(section, "lib_deps")
+	for _, entry := range strings.FieldsFunc(libDeps, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		dep, err := i.dependency(entry)
+		if err != nil {
+			i.note(env, fmt.Sprintf("lib_deps %s: %s", entry, err))
+			continue
+		}
+		if !containsDependency(result, *dep) {
+			result = append(result, *dep)
+		}
+	}
+	return result
+}
+
+func (i *platformioImporter) dependency(entry string) (*ProjectDependency, error) {
+	// named sources (NAME=SOURCE)
+	if equals := strings.Index(entry, "="); equals > 0 && strings.Contains(entry[equals+1:], "://") {
+		entry = strings.TrimSpace(entry[equals+1:])
+	}
+	lower := strings.ToLower(entry)
+	isArchive := strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+	switch {
+	case strings.HasPrefix(lower, "symlink://"):
+		return nil, errors.New("symlinked libraries are not supported")
+	case strings.HasPrefix(lower, "file://") || (isArchive && !strings.Contains(lower, "://")):
+		if strings.HasPrefix(lower, "file://") {
+			entry = entry[len("file://"):]
+		}
+		return i.zipDependency(entry)
+	case isArchive:
+		return nil, errors.New("archives are only supported as local zip files")
+	case strings.HasPrefix(lower, "git+") || strings.Contains(lower, "://") || strings.HasPrefix(lower, "git@"):
+		url := strings.TrimPrefix(entry, "git+")
+		dep := &ProjectDependency{Git: url}
+		if hash := strings.LastIndex(url, "#"); hash >= 0 {
+			dep.Git = url[:hash]
+			dep.Ref = url[hash+1:]
+		}
+		return dep, nil
+	}
+
+	// registry library: [OWNER/]NAME[@VERSION]
+	name, version := entry, "latest"
+	if at := strings.Index(entry, "@"); at >= 0 {
+		name = strings.TrimSpace(entry[:at])
+		version = strings.TrimSpace(entry[at+1:])
+	}
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = strings.TrimSpace(name[slash+1:])
+	}
+	if name == "" {
+		return nil, errors.New("no library name")
+	}
+	if isNumeric(name) {
+		return nil, errors.New("libraries can not be referenced by their PlatformIO registry ID")
+	}
+	if _, err := ParseVersionSpec(version); err != nil {
+		return nil, errors.New(fmt.Sprintf("unsupported version '%s'", version))
+	}
+	return &ProjectDependency{Library: name, Version: version}, nil
+}
+
+// zipDependency returns the dependency of a local zip file, its path is relative to the apm project
+func (i *platformioImporter) zipDependency(path string) (*ProjectDependency, error) {
+	if !strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return nil, errors.New("local libraries are only supported as zip files")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(i.dir, path)
+	}
+	if !util.FileExists(path) {
+		return nil, errors.New(fmt.Sprintf("'%s' not found", path))
+	}
+	hash, err := util.FileSha256(path)
+	if err != nil {
+		return nil, err
+	}
+	if relPath, err := filepath.Rel(i.projectDir, path); err == nil && !strings.HasPrefix(relPath, "..") {
+		path = filepath.ToSlash(relPath)
+	}
+	return &ProjectDependency{Zip: path, Sha256: hash}, nil
+}
+
+// splitPlatformioVersion splits NAME@VERSION (or NAME @ VERSION) of a platform
+func splitPlatformioVersion(value string) (string, string) {
+	if at := strings.Index(value, "@"); at >= 0 {
+		return strings.TrimSpace(value[:at]), strings.TrimSpace(value[at+1:])
+	}
+	return strings.TrimSpace(value), ""
+}
+
+func isNumeric(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
@@ -0,0 +1,151 @@
+package project
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlatformioDependency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm-platformio-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "lib.zip"), []byte("zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zipSha256 := "4a70fe9aa6436e02c2dea340fbd1e352e4ef2d8ce6ca52ad25d4b95471fc8bf2"
+
+	tests := []struct {
+		entry string
+		want  *ProjectDependency
+		err   bool
+	}{
+		{entry: "Servo", want: &ProjectDependency{Library: "Servo", Version: "latest"}},
+		{entry: "Servo@1.1.8", want: &ProjectDependency{Library: "Servo", Version: "1.1.8"}},
+		{entry: "adafruit/DHT sensor library @ ^1.4.3", want: &ProjectDependency{Library: "DHT sensor library", Version: "^1.4.3"}},
+		{entry: "https://github.com/example/sensor.git", want: &ProjectDependency{Git: "https://github.com/example/sensor.git"}},
+		{entry: "git+https://github.com/example/sensor.git#v1.2", want: &ProjectDependency{Git: "https://github.com/example/sensor.git", Ref: "v1.2"}},
+		{entry: "Sensor=https://github.com/example/sensor.git", want: &ProjectDependency{Git: "https://github.com/example/sensor.git"}},
+		{entry: "git@github.com:example/sensor.git", want: &ProjectDependency{Git: "git@github.com:example/sensor.git"}},
+		{entry: "lib.zip", want: &ProjectDependency{Zip: "lib.zip", Sha256: zipSha256}},
+		{entry: "file://lib.zip", want: &ProjectDependency{Zip: "lib.zip", Sha256: zipSha256}},
+		{entry: "missing.zip", err: true},
+		{entry: "file://lib", err: true},
+		{entry: "https://example.com/lib.zip", err: true},
+		{entry: "symlink://../lib", err: true},
+		{entry: "1234", err: true},
+		{entry: "Servo@~>1.0", err: true},
+	}
+	importer := &platformioImporter{dir: dir, projectDir: dir}
+	for _, test := range tests {
+		got, err := importer.dependency(test.entry)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: no error, got %+v", test.entry, *got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.entry, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.entry, *got, *test.want)
+		}
+	}
+}
+
+func TestImportPlatformio(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm-platformio-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ini := `; weather station
+[platformio]
+default_envs = uno
+
+[env]
+framework = arduino
+lib_deps =
+    Servo@1.1.8
+    adafruit/DHT sensor library
+
+[env:uno]
+platform = atmelavr
+board = nanoatmega328
+build_flags = -D DEBUG -DLED_PIN=2 -Wall
+
+[env:esp]
+platform = platformio/espressif8266@4.0.1
+board = nodemcuv2
+lib_deps =
+    ${env.lib_deps}
+    ESP8266WiFi
+monitor_speed = 115200
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, PlatformioFileName), []byte(ini), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	details, untranslated, err := ImportPlatformio(dir, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ProjectDetails{
+		Board: &ProjectBoard{
+			Package:      "arduino",
+			Architecture: "avr",
+			Version:      "latest",
+			Board:        "nano",
+			Options:      map[string]string{"cpu": "atmega328old"},
+			Defines:      []string{"DEBUG", "LED_PIN=2"},
+		},
+		Dependencies: []ProjectDependency{
+			{Library: "Servo", Version: "1.1.8"},
+			{Library: "DHT sensor library", Version: "latest"},
+		},
+		Environments: map[string]*ProjectEnvironment{
+			"uno": {},
+			"esp": {
+				Board: &ProjectBoard{
+					Package:          "esp8266",
+					Architecture:     "esp8266",
+					Version:          "latest",
+					BoardManagerUrls: []string{"https://arduino.esp8266.com/stable/package_esp8266com_index.json"},
+					Board:            "nodemcuv2",
+				},
+				Dependencies: []ProjectDependency{{Library: "ESP8266WiFi", Version: "latest"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(details, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", details, want)
+	}
+	wantUntranslated := []string{
+		"[env:uno] build flag -Wall",
+		"[env:esp] monitor_speed = 115200",
+		"[env:esp] platform version 4.0.1 is a version of the PlatformIO platform, the latest Arduino core is used",
+	}
+	if !reflect.DeepEqual(untranslated, wantUntranslated) {
+		t.Errorf("untranslated:\n%q\nwant:\n%q", untranslated, wantUntranslated)
+	}
+}
+
+func TestImportPlatformioWithoutEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm-platformio-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, PlatformioFileName), []byte("[platformio]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ImportPlatformio(dir, dir); err == nil {
+		t.Errorf("no error without environments")
+	}
+}
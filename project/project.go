@@ -7,58 +7,270 @@ import (
 	"github.com/ksrichard/apm/util"
 	"github.com/spf13/cobra"
 	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 var ProjectDetailsFileName string = "apm.json"
+var IsolationDirName string = ".apm"
+var VendorDirName string = "vendor"
+var BuildDirName string = "build"
 
 func GetProjectDir(cmd *cobra.Command) (string, error) {
 	return cmd.Flags().GetString("project-dir")
 }
 
+// GetProjectDocument reads and parses the project file (apm.json, apm.yaml or apm.toml) of the project directory,
+// project files of an older schema version are migrated to the current one (the file is not changed)
+func GetProjectDocument(cmd *cobra.Command) (*Document, error) {
+	document, err := ReadProjectDocument(cmd)
+	if err != nil {
+		return nil, err
+	}
+	migrated, _, err := document.Migrate()
+	if err != nil {
+		return nil, err
+	}
+	if migrated != document {
+		version, _ := document.SchemaVersion()
+		log.Printf("WARNING: '%s' has schema version %d, please run 'apm migrate' to upgrade it to schema version %d (commands changing it migrate it)\n", document.File, version, CurrentSchemaVersion)
+	}
+	return migrated, nil
+}
+
+// ReadProjectDocument reads and parses the project file of the project directory as it is
+func ReadProjectDocument(cmd *cobra.Command) (*Document, error) {
+	projectDir, err := GetProjectDir(cmd)
+	if err != nil {
+		return nil, err
+	}
+	filePath, err := FindProjectFile(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	if filePath == "" {
+		return nil, errors.New(fmt.Sprintf("'%s/%s' not found!", projectDir, ProjectDetailsFileName))
+	}
+	fileData, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	document, err := ParseDocument(filePath, fileData)
+	if err != nil {
+		return nil, err
+	}
+	document.Dir = projectDir
+	return document, nil
+}
+
+// GetProjectDetails reads the project file, it fails with all problems found by the validation
 func GetProjectDetails(cmd *cobra.Command) (*ProjectDetails, error) {
+	document, err := GetProjectDocument(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if problems := document.Validate(); len(problems) > 0 {
+		return nil, errors.New(fmt.Sprintf("invalid '%s':\n%s", document.File, FormatProblems(problems)))
+	}
+	return document.Details()
+}
+
+// Details returns the project described by the document
+func (d *Document) Details() (*ProjectDetails, error) {
 	var result ProjectDetails
-	projectDir, err := GetProjectDir(cmd)
+	jsonData, err := d.toJson()
 	if err != nil {
 		return nil, err
 	}
-	jsonFilePath := fmt.Sprintf("%s/%s", projectDir, ProjectDetailsFileName)
-	if util.FileExists(jsonFilePath) {
-		jsonFile, err := ioutil.ReadFile(jsonFilePath)
-		if err != nil {
-			return nil, err
-		}
-		err = json.Unmarshal(jsonFile, &result)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		return nil, errors.New(fmt.Sprintf("'%s' not found!", jsonFilePath))
+	err = json.Unmarshal(jsonData, &result)
+	if err != nil {
+		return nil, err
 	}
+	result.Dir = d.Dir
+	result.document = d
 	return &result, nil
 }
 
-func UpdateProjectDetails(cmd *cobra.Command, details *ProjectDetails) error {
-	projectDir, err := GetProjectDir(cmd)
+// Name returns the name of the project (name of the project directory)
+func (d *ProjectDetails) Name() string {
+	dir, err := filepath.Abs(d.Dir)
+	if d.Dir == "" || err != nil {
+		return "project"
+	}
+	return filepath.Base(dir)
+}
+
+// FileName returns the name of the project file (e.g. apm.yaml), apm.json if the project was not read from a file
+func (d *ProjectDetails) FileName() string {
+	if d.document == nil || d.document.File == "" {
+		return ProjectDetailsFileName
+	}
+	return filepath.Base(d.document.File)
+}
+
+// LockFileName returns the name of the lock file of the selected environment
+func (d *ProjectDetails) LockFileName() string {
+	return LockFileName(d.Environment)
+}
+
+// IsolationDir returns the project local directory for libraries and cores or an empty string if isolation is disabled
+func (d *ProjectDetails) IsolationDir() string {
+	if !d.Isolation {
+		return ""
+	}
+	dir, err := filepath.Abs(d.Dir)
 	if err != nil {
-		return err
+		dir = d.Dir
 	}
-	jsonFilePath := fmt.Sprintf("%s/%s", projectDir, ProjectDetailsFileName)
-	if util.FileExists(jsonFilePath) {
-		fileData, err := json.MarshalIndent(details, "", "    ")
-		if err != nil {
-			return err
+	return filepath.Join(dir, IsolationDirName, d.Environment)
+}
+
+// VendorDir returns the path of the vendored libraries or an empty string if the project has no vendored libraries
+func (d *ProjectDetails) VendorDir() string {
+	if d.Vendor == "" {
+		return ""
+	}
+	if d.Environment != "" {
+		return fmt.Sprintf("%s/%s/%s", d.Dir, d.Vendor, d.Environment)
+	}
+	return fmt.Sprintf("%s/%s", d.Dir, d.Vendor)
+}
+
+// FQBN returns the fully qualified board name used to build the project including the board options
+func (b *ProjectBoard) FQBN() (string, error) {
+	fqbn, err := b.BoardFQBN()
+	if err != nil {
+		return "", err
+	}
+	if len(b.Options) == 0 {
+		return fqbn, nil
+	}
+	var keys []string
+	for key := range b.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var options []string
+	for _, key := range keys {
+		options = append(options, fmt.Sprintf("%s=%s", key, b.Options[key]))
+	}
+	// options of the fqbn field are kept, the board options are added after them
+	separator := ":"
+	if strings.Count(fqbn, ":") > 2 {
+		separator = ","
+	}
+	return fqbn + separator + strings.Join(options, ","), nil
+}
+
+// IndexUrls returns the board manager URLs of the board without duplicates
+func (b *ProjectBoard) IndexUrls() []string {
+	var result []string
+	for _, url := range b.BoardManagerUrls {
+		url = strings.TrimSpace(url)
+		if url != "" && !containsString(result, url) {
+			result = append(result, url)
 		}
-		err = os.Remove(jsonFilePath)
-		if err != nil {
-			return err
+	}
+	return result
+}
+
+// ID returns the platform ID, e.g. arduino:avr
+func (p ProjectPlatform) ID() string {
+	return fmt.Sprintf("%s:%s", p.Package, p.Architecture)
+}
+
+// BoardFQBN returns the fully qualified board name without the board options
+func (b *ProjectBoard) BoardFQBN() (string, error) {
+	if b.Fqbn != "" {
+		return b.Fqbn, nil
+	}
+	if b.Board != "" && b.Package != "" && b.Architecture != "" {
+		return fmt.Sprintf("%s:%s:%s", b.Package, b.Architecture, b.Board), nil
+	}
+	return "", errors.New(fmt.Sprintf("please set 'fqbn' or 'board' of the board in '%s'", ProjectDetailsFileName))
+}
+
+// CompileProperties returns the build properties of the board with the defines added to build.extra_flags
+func (b *ProjectBoard) CompileProperties() []string {
+	var result []string
+	var extraFlags []string
+	for _, property := range b.BuildProperties {
+		if strings.HasPrefix(property, "build.extra_flags=") {
+			if flags := strings.TrimPrefix(property, "build.extra_flags="); flags != "" {
+				extraFlags = append(extraFlags, flags)
+			}
+			continue
 		}
-		err = ioutil.WriteFile(jsonFilePath, fileData, os.ModePerm)
+		result = append(result, property)
+	}
+	for _, define := range b.Defines {
+		extraFlags = append(extraFlags, "-D"+define)
+	}
+	if len(extraFlags) > 0 {
+		result = append(result, "build.extra_flags="+strings.Join(extraFlags, " "))
+	}
+	return result
+}
+
+// OutputDir returns the directory of the compiled binaries of the project
+func (d *ProjectDetails) OutputDir() string {
+	outputDir := BuildDirName
+	if d.Board != nil && d.Board.Build != nil && d.Board.Build.OutputDir != "" {
+		outputDir = d.Board.Build.OutputDir
+	}
+	if d.Environment != "" {
+		outputDir = filepath.Join(outputDir, d.Environment)
+	}
+	if filepath.IsAbs(outputDir) {
+		return outputDir
+	}
+	return fmt.Sprintf("%s/%s", d.Dir, outputDir)
+}
+
+// UpdateProjectDetails writes the project file in its format, comments of YAML and TOML files are kept.
+// A project file of an older schema version is written with the current one, the changes of its migration are printed.
+func UpdateProjectDetails(cmd *cobra.Command, details *ProjectDetails) error {
+	if details.document == nil {
+		document, err := GetProjectDocument(cmd)
 		if err != nil {
 			return err
 		}
-	} else {
-		return errors.New(fmt.Sprintf("'%s' not found!", jsonFilePath))
+		details.document = document
+	}
+	details.document.logMigration()
+	return writeProjectDetails(cmd, details)
+}
+
+// writeProjectDetails writes the project file in its format
+func writeProjectDetails(cmd *cobra.Command, details *ProjectDetails) error {
+	projectDir, err := GetProjectDir(cmd)
+	if err != nil {
+		return err
+	}
+	previous := details.document
+	if previous == nil {
+		return errors.New("the project file is not loaded")
+	}
+	fileData, err := MarshalProjectDetails(details, previous.Format, previous)
+	if err != nil {
+		return err
+	}
+	filePath := previous.File
+	if !util.FileExists(filePath) {
+		return errors.New(fmt.Sprintf("'%s' not found!", filePath))
+	}
+	err = util.WriteFileAtomic(filePath, fileData, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	document, err := ParseDocument(filePath, fileData)
+	if err != nil {
+		return err
 	}
+	document.Dir = projectDir
+	details.document = document
 	return nil
 }
@@ -0,0 +1,384 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// merger merges a new version of a project file into the previous one: values which did not change are kept
+// as they are (in JSON files with their exact bytes), keys keep their order and unknown keys are retained
+type merger struct {
+	// nodes of the previous project file which are kept unchanged
+	originals map[*jsonNode]bool
+	// changed and new objects and arrays with a node of the previous project file, they are written with its layout
+	layouts map[*jsonNode]*jsonNode
+	// nodes changed by the migrations of the previous project file with the nodes they replace, they are always rewritten
+	replaced map[*jsonNode]*jsonNode
+}
+
+func newMerger() *merger {
+	return &merger{originals: make(map[*jsonNode]bool), layouts: make(map[*jsonNode]*jsonNode)}
+}
+
+// merge returns the merged node of a value, t is the Go type of the value (nil if unknown)
+func (m *merger) merge(old *jsonNode, new *jsonNode, t reflect.Type) *jsonNode {
+	if old == nil || old.Kind != new.Kind {
+		return new
+	}
+	switch new.Kind {
+	case "object":
+		return m.mergeObject(old, new, t)
+	case "array":
+		return m.mergeArray(old, new, t)
+	}
+	if _, ok := m.replaced[old]; ok || fmt.Sprint(old.Value) != fmt.Sprint(new.Value) {
+		return new
+	}
+	m.originals[old] = true
+	return old
+}
+
+func (m *merger) mergeObject(old *jsonNode, new *jsonNode, t reflect.Type) *jsonNode {
+	managed := managedKeys(t)
+	result := &jsonNode{Kind: "object"}
+	_, changed := m.replaced[old]
+	for _, field := range old.Fields {
+		newValue := new.field(field.Key)
+		if newValue == nil {
+			// keys of apm which are not set anymore are removed, unknown keys are retained
+			if managed == nil || managed[field.Key] {
+				changed = true
+				continue
+			}
+			m.originals[field.Value] = true
+			result.Fields = append(result.Fields, field)
+			continue
+		}
+		value := m.merge(field.Value, newValue, fieldType(t, field.Key))
+		changed = changed || value != field.Value
+		result.Fields = append(result.Fields, &jsonField{Key: field.Key, Value: value})
+	}
+
+	// new keys are inserted after the key before them in the new version
+	for i, field := range new.Fields {
+		if old.field(field.Key) != nil || field.Value.Kind == "null" {
+			continue
+		}
+		changed = true
+		position := 0
+		for j := i - 1; j >= 0 && position == 0; j-- {
+			for k, existing := range result.Fields {
+				if existing.Key == new.Fields[j].Key {
+					position = k + 1
+					break
+				}
+			}
+		}
+		result.Fields = append(result.Fields, nil)
+		copy(result.Fields[position+1:], result.Fields[position:])
+		result.Fields[position] = &jsonField{Key: field.Key, Value: field.Value}
+	}
+
+	if !changed {
+		m.originals[old] = true
+		return old
+	}
+	m.layouts[result] = m.layoutOf(old)
+	return result
+}
+
+func (m *merger) mergeArray(old *jsonNode, new *jsonNode, t reflect.Type) *jsonNode {
+	var itemType reflect.Type
+	if t != nil {
+		itemType = fieldType(t, "")
+	}
+	result := &jsonNode{Kind: "array"}
+	_, changed := m.replaced[old]
+	changed = changed || len(old.Items) != len(new.Items)
+	used := make(map[int]bool)
+	for i, item := range new.Items {
+		// items are matched by their identity (e.g. the library name), so they can be moved
+		identity := itemIdentity(item, i)
+		var oldItem *jsonNode
+		for j, candidate := range old.Items {
+			if !used[j] && itemIdentity(candidate, j) == identity {
+				oldItem = candidate
+				used[j] = true
+				break
+			}
+		}
+		value := m.merge(oldItem, item, itemType)
+		if oldItem == nil && len(old.Items) > 0 && old.Items[0].Kind == item.Kind {
+			// new items look like the existing ones
+			m.layouts[value] = m.layoutOf(old.Items[0])
+		}
+		changed = changed || i >= len(old.Items) || value != old.Items[i]
+		result.Items = append(result.Items, value)
+	}
+	if !changed {
+		m.originals[old] = true
+		return old
+	}
+	m.layouts[result] = m.layoutOf(old)
+	return result
+}
+
+// layoutOf returns the node of the previous project file with the layout of a node,
+// a node changed by a migration has the layout of the node it replaces
+func (m *merger) layoutOf(node *jsonNode) *jsonNode {
+	if replaced, ok := m.replaced[node]; ok {
+		return replaced
+	}
+	return node
+}
+
+// styleOf returns the node of the previous project file whose style is used for a node of the same kind: the node
+// itself if it is unchanged, the node with its layout or the fallback (e.g. the previous value of the same key)
+func (m *merger) styleOf(node *jsonNode, fallback *jsonNode) *jsonNode {
+	result := fallback
+	if m.originals[node] {
+		result = node
+	} else if old := m.layouts[node]; old != nil {
+		result = old
+	}
+	if result == nil || result.Kind != node.Kind {
+		return nil
+	}
+	return result
+}
+
+// managedKeys returns the keys of a struct written by apm or nil if every key is managed (e.g. maps)
+func managedKeys(t reflect.Type) map[string]bool {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	result := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonFieldName(t.Field(i)); name != "" {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+// fieldType returns the type of a field of a struct or the type of the values of a map or slice
+func fieldType(t reflect.Type, key string) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return t.Elem()
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if jsonFieldName(t.Field(i)) == key {
+				return t.Field(i).Type
+			}
+		}
+	}
+	return nil
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// jsonWriter writes a merged JSON project file, unchanged values are copied from the previous project file
+// and changed objects and arrays keep their previous layout
+type jsonWriter struct {
+	buffer  bytes.Buffer
+	data    []byte
+	merger  *merger
+	unit    string
+	newline string
+	// layout of the object or array being written
+	parent jsonLayout
+}
+
+// jsonLayout is the layout of an object or array
+type jsonLayout struct {
+	// written in one line
+	inline bool
+	// indentation of the items and of the closing bracket
+	itemIndent    string
+	closingIndent string
+	// separator of keys and values and the space after the commas of objects and arrays written in one line
+	colon string
+	space string
+}
+
+// writeJson returns the merged JSON project file
+func writeJson(previous *Document, root *jsonNode, m *merger) []byte {
+	if previous.Format != FormatJson {
+		// converted from another format
+		writer := &jsonWriter{merger: newMerger(), unit: "    ", newline: "\n", parent: jsonLayout{colon: ": ", space: " "}}
+		writer.writeNode(root, "")
+		return writer.buffer.Bytes()
+	}
+	writer := &jsonWriter{data: previous.data, merger: m, unit: previous.indentUnit(), newline: "\n", parent: jsonLayout{colon: ": ", space: " "}}
+	if bytes.Contains(previous.data, []byte("\r\n")) {
+		writer.newline = "\r\n"
+	}
+	writer.buffer.Write(previous.data[:previous.root.Start])
+	writer.writeNode(root, previous.lineIndent(previous.root.Start))
+	writer.buffer.Write(previous.data[previous.root.End:])
+	return writer.buffer.Bytes()
+}
+
+func (w *jsonWriter) writeNode(node *jsonNode, indent string) {
+	if w.merger.originals[node] {
+		w.buffer.Write(w.data[node.Start:node.End])
+		return
+	}
+	switch node.Kind {
+	case "object":
+		if len(node.Fields) == 0 {
+			w.buffer.WriteString("{}")
+			return
+		}
+		layout := w.layout(node, indent)
+		parent := w.parent
+		w.parent = layout
+		w.buffer.WriteString("{")
+		for i, field := range node.Fields {
+			w.separator(i, layout)
+			key, _ := json.Marshal(field.Key)
+			w.buffer.Write(key)
+			w.buffer.WriteString(layout.colon)
+			w.writeNode(field.Value, layout.itemIndent)
+		}
+		w.parent = parent
+		w.closing(layout)
+		w.buffer.WriteString("}")
+	case "array":
+		if len(node.Items) == 0 {
+			w.buffer.WriteString("[]")
+			return
+		}
+		layout := w.layout(node, indent)
+		parent := w.parent
+		w.parent = layout
+		w.buffer.WriteString("[")
+		for i, item := range node.Items {
+			w.separator(i, layout)
+			w.writeNode(item, layout.itemIndent)
+		}
+		w.parent = parent
+		w.closing(layout)
+		w.buffer.WriteString("]")
+	case "string":
+		value, _ := json.Marshal(node.Value)
+		w.buffer.Write(value)
+	case "null":
+		w.buffer.WriteString("null")
+	default:
+		w.buffer.WriteString(fmt.Sprint(node.Value))
+	}
+}
+
+func (w *jsonWriter) separator(i int, layout jsonLayout) {
+	if i > 0 {
+		w.buffer.WriteString(",")
+	}
+	if layout.inline {
+		if i > 0 {
+			w.buffer.WriteString(layout.space)
+		}
+		return
+	}
+	w.buffer.WriteString(w.newline + layout.itemIndent)
+}
+
+func (w *jsonWriter) closing(layout jsonLayout) {
+	if !layout.inline {
+		w.buffer.WriteString(w.newline + layout.closingIndent)
+	}
+}
+
+// layout returns the layout of an object or array, the layout of the previous version is used if possible.
+// New and empty objects and arrays get the layout of the object or array they are in: in one line if it is written
+// in one line (e.g. a compact apm.json) with its separators.
+func (w *jsonWriter) layout(node *jsonNode, indent string) jsonLayout {
+	result := jsonLayout{itemIndent: indent + w.unit, closingIndent: indent, colon: w.parent.colon, space: w.parent.space}
+	old := w.merger.layouts[node]
+	if old == nil {
+		result.inline = w.parent.inline
+		return result
+	}
+	firstStart, lastEnd, separator := -1, -1, ""
+	if len(old.Fields) > 0 {
+		first := old.Fields[0]
+		firstStart = first.KeyStart
+		lastEnd = old.Fields[len(old.Fields)-1].Value.End
+		result.colon = string(w.data[first.KeyEnd:first.Value.Start])
+		if len(old.Fields) > 1 {
+			separator = string(w.data[first.Value.End:old.Fields[1].KeyStart])
+		}
+	} else if len(old.Items) > 0 {
+		firstStart = old.Items[0].Start
+		lastEnd = old.Items[len(old.Items)-1].End
+		if len(old.Items) > 1 {
+			separator = string(w.data[old.Items[0].End:old.Items[1].Start])
+		}
+	} else {
+		result.inline = w.parent.inline
+		return result
+	}
+	opening := string(w.data[old.Start+1 : firstStart])
+	if !strings.Contains(opening, "\n") {
+		result.inline = true
+		if separator != "" && !strings.Contains(separator, "\n") {
+			result.space = strings.Replace(separator, ",", "", 1)
+		}
+		return result
+	}
+	result.itemIndent = opening[strings.LastIndex(opening, "\n")+1:]
+	closing := string(w.data[lastEnd : old.End-1])
+	if i := strings.LastIndex(closing, "\n"); i >= 0 {
+		result.closingIndent = closing[i+1:]
+	}
+	return result
+}
+
+// lineIndent returns the whitespace at the beginning of the line of an offset
+func (d *Document) lineIndent(offset int) string {
+	start := bytes.LastIndexByte(d.data[:offset], '\n') + 1
+	end := start
+	for end < len(d.data) && (d.data[end] == ' ' || d.data[end] == '\t') {
+		end++
+	}
+	return string(d.data[start:end])
+}
+
+// indentUnit returns the indentation of the JSON file detected from its first indented key (4 spaces by default)
+func (d *Document) indentUnit() string {
+	if d.root != nil && len(d.root.Fields) > 0 {
+		rootIndent := d.lineIndent(d.root.Start)
+		first := d.root.Fields[0]
+		if first.KeyLine > d.root.Line {
+			if indent := d.lineIndent(first.KeyStart); len(indent) > len(rootIndent) {
+				return indent[len(rootIndent):]
+			}
+		}
+	}
+	return "    "
+}
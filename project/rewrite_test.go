@@ -0,0 +1,84 @@
+package project
+
+import (
+	"testing"
+)
+
+func TestMarshalProjectDetailsKeepsJsonLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "tab indent",
+			input: "{\n\t\"board\": null,\n\t\"dependencies\": []\n}\n",
+			want:  "{\n\t\"board\": null,\n\t\"dependencies\": [\n\t\t{\n\t\t\t\"library\": \"DHT sensor library\",\n\t\t\t\"version\": \"1.0\"\n\t\t}\n\t],\n\t\"isolation\": true\n}\n",
+		},
+		{
+			name:  "2 spaces indent",
+			input: "{\n  \"board\": null,\n  \"dependencies\": [\n    {\n      \"library\": \"Servo\",\n      \"version\": \"1.8.3\"\n    }\n  ]\n}\n",
+			want:  "{\n  \"board\": null,\n  \"dependencies\": [\n    {\n      \"library\": \"Servo\",\n      \"version\": \"1.8.3\"\n    },\n    {\n      \"library\": \"DHT sensor library\",\n      \"version\": \"1.0\"\n    }\n  ],\n  \"isolation\": true\n}\n",
+		},
+		{
+			name:  "compact one-line file",
+			input: `{"board":null,"dependencies":[{"library":"Servo","version":"1.8.3"}]}`,
+			want:  `{"board":null,"dependencies":[{"library":"Servo","version":"1.8.3"},{"library":"DHT sensor library","version":"1.0"}],"isolation":true}`,
+		},
+		{
+			name:  "new keys after the known keys before them",
+			input: "{\n  \"schema_version\": 2,\n  \"board\": null,\n  \"build_flags\": []\n}\n",
+			want:  "{\n  \"schema_version\": 2,\n  \"board\": null,\n  \"dependencies\": [\n    {\n      \"library\": \"DHT sensor library\",\n      \"version\": \"1.0\"\n    }\n  ],\n  \"isolation\": true,\n  \"build_flags\": []\n}\n",
+		},
+		{
+			name:  "x- keys are kept",
+			input: "{\n  \"x-owner\": \"firmware team\",\n  \"board\": null,\n  \"x-notes\": {\"reviewed\": true}\n}\n",
+			want:  "{\n  \"x-owner\": \"firmware team\",\n  \"board\": null,\n  \"dependencies\": [\n    {\n      \"library\": \"DHT sensor library\",\n      \"version\": \"1.0\"\n    }\n  ],\n  \"isolation\": true,\n  \"x-notes\": {\"reviewed\": true}\n}\n",
+		},
+		{
+			name:  "CRLF file",
+			input: "{\r\n  \"board\": null,\r\n  \"dependencies\": [\r\n    {\"library\": \"Servo\", \"version\": \"1.8.3\"}\r\n  ]\r\n}\r\n",
+			want:  "{\r\n  \"board\": null,\r\n  \"dependencies\": [\r\n    {\"library\": \"Servo\", \"version\": \"1.8.3\"},\r\n    {\"library\": \"DHT sensor library\", \"version\": \"1.0\"}\r\n  ],\r\n  \"isolation\": true\r\n}\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, err := ParseDocument(ProjectDetailsFileName, []byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			details, err := document.Details()
+			if err != nil {
+				t.Fatal(err)
+			}
+			details.Dependencies = append(details.Dependencies, ProjectDependency{Library: "DHT sensor library", Version: "1.0"})
+			details.Isolation = true
+			data, err := MarshalProjectDetails(details, FormatJson, document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("got:\n%q\nwant:\n%q", data, test.want)
+			}
+		})
+	}
+}
+
+func TestMarshalProjectDetailsUnchangedJson(t *testing.T) {
+	input := "{\n    \"board\" : null ,\n    \"dependencies\": [ ]\n}"
+	document, err := ParseDocument(ProjectDetailsFileName, []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, err := document.Details()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MarshalProjectDetails(details, FormatJson, document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != input {
+		t.Errorf("got:\n%q\nwant:\n%q", data, input)
+	}
+}
@@ -0,0 +1,229 @@
+package project
+
+// Schema is the JSON Schema of the project file, it is published as apm.schema.json
+// in the repository (apm validate --print-schema > apm.schema.json)
+var Schema string = `{
+    "$schema": "http://json-schema.org/draft-07/schema#",
+    "$id": "https://raw.githubusercontent.com/ksrichard/apm/main/apm.schema.json",
+    "title": "apm.json",
+    "description": "Project file of the Arduino Package Manager",
+    "type": "object",
+    "properties": {
+        "$schema": {
+            "type": "string"
+        },
+        "schema_version": {
+            "description": "schema version of the project file, older project files are migrated by apm migrate",
+            "type": "integer",
+            "minimum": 1
+        },
+        "board": {
+            "$ref": "#/definitions/board"
+        },
+        "dependencies": {
+            "$ref": "#/definitions/dependencies"
+        },
+        "dev_dependencies": {
+            "$ref": "#/definitions/dependencies"
+        },
+        "groups": {
+            "description": "named dependency groups installed together with the dependencies",
+            "type": "object",
+            "additionalProperties": {
+                "type": "object",
+                "properties": {
+                    "optional": {
+                        "description": "install the group only if it is selected with --with",
+                        "type": "boolean"
+                    },
+                    "dependencies": {
+                        "$ref": "#/definitions/dependencies"
+                    }
+                },
+                "patternProperties": {
+                    "^x-": {}
+                },
+                "additionalProperties": false
+            }
+        },
+        "isolation": {
+            "description": "install libraries and cores into the .apm directory of the project",
+            "type": "boolean"
+        },
+        "vendor": {
+            "description": "directory of the vendored libraries",
+            "type": "string"
+        },
+        "environments": {
+            "description": "named build environments",
+            "type": "object",
+            "additionalProperties": {
+                "type": "object",
+                "properties": {
+                    "board": {
+                        "$ref": "#/definitions/board"
+                    },
+                    "dependencies": {
+                        "$ref": "#/definitions/dependencies"
+                    }
+                },
+                "patternProperties": {
+                    "^x-": {}
+                },
+                "additionalProperties": false
+            }
+        }
+    },
+    "patternProperties": {
+        "^x-": {}
+    },
+    "additionalProperties": false,
+    "definitions": {
+        "board": {
+            "type": ["object", "null"],
+            "properties": {
+                "package": {
+                    "description": "Arduino core package name",
+                    "type": "string"
+                },
+                "architecture": {
+                    "description": "architecture of the Arduino core package",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "version of the core package, latest or a version range",
+                    "type": "string"
+                },
+                "board_manager_urls": {
+                    "description": "additional board manager URLs of the core package and of the additional platforms",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "board": {
+                    "description": "board ID in the core package",
+                    "type": "string"
+                },
+                "fqbn": {
+                    "description": "fully qualified board name",
+                    "type": "string"
+                },
+                "options": {
+                    "description": "board menu options appended to the FQBN",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "build_properties": {
+                    "description": "build properties used by every compile",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "defines": {
+                    "description": "preprocessor defines added to build.extra_flags",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "build": {
+                    "type": "object",
+                    "properties": {
+                        "output_dir": {
+                            "type": "string"
+                        },
+                        "warnings": {
+                            "enum": ["none", "default", "more", "all"]
+                        },
+                        "optimize_for_debug": {
+                            "type": "boolean"
+                        },
+                        "verbose": {
+                            "type": "boolean"
+                        },
+                        "jobs": {
+                            "type": "integer",
+                            "minimum": 0
+                        }
+                    },
+                    "patternProperties": {
+                        "^x-": {}
+                    },
+                    "additionalProperties": false
+                },
+                "platforms": {
+                    "description": "additional platforms needed by the board, installed before its core package",
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "package": {
+                                "type": "string"
+                            },
+                            "architecture": {
+                                "type": "string"
+                            },
+                            "version": {
+                                "description": "version of the platform, latest or a version range",
+                                "type": "string"
+                            }
+                        },
+                        "required": ["package", "architecture", "version"],
+                        "patternProperties": {
+                            "^x-": {}
+                        },
+                        "additionalProperties": false
+                    }
+                }
+            },
+            "patternProperties": {
+                "^x-": {}
+            },
+            "additionalProperties": false
+        },
+        "dependencies": {
+            "type": ["array", "null"],
+            "items": {
+                "$ref": "#/definitions/dependency"
+            }
+        },
+        "dependency": {
+            "type": "object",
+            "properties": {
+                "library": {
+                    "description": "Arduino library name",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "library version, latest or a version range",
+                    "type": "string"
+                },
+                "git": {
+                    "description": "URL of a git repository",
+                    "type": "string"
+                },
+                "ref": {
+                    "description": "branch, tag or commit of the git repository",
+                    "type": "string"
+                },
+                "zip": {
+                    "description": "path of a zip file",
+                    "type": "string"
+                },
+                "sha256": {
+                    "description": "expected sha256 of the zip file or of the sources of the git repository",
+                    "type": "string"
+                }
+            },
+            "patternProperties": {
+                "^x-": {}
+            },
+            "additionalProperties": false
+        }
+    }
+}
+`
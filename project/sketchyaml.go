@@ -0,0 +1,311 @@
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SketchYamlFileName is the file of the build profiles of arduino-cli
+var SketchYamlFileName = "sketch.yaml"
+
+// NAME (VERSION) entries of the platforms and libraries of a profile
+var sketchYamlEntryRegex = regexp.MustCompile(`^\s*(.+?)\s*(?:\(\s*([^)]*?)\s*\))?\s*$`)
+
+// SketchProfile is a build profile of sketch.yaml with pinned platforms and libraries
+type SketchProfile struct {
+	Notes     string           `yaml:"notes,omitempty"`
+	Fqbn      string           `yaml:"fqbn"`
+	Platforms []SketchPlatform `yaml:"platforms"`
+	Libraries []string         `yaml:"libraries,omitempty"`
+}
+
+type SketchPlatform struct {
+	// platform ID and version, e.g. arduino:avr (1.8.3)
+	Platform         string `yaml:"platform"`
+	PlatformIndexUrl string `yaml:"platform_index_url,omitempty"`
+}
+
+// sketchProfileKeys are the keys of a profile which are imported
+var sketchProfileKeys = map[string]bool{"notes": true, "fqbn": true, "platforms": true, "libraries": true}
+
+// SketchProfileOf returns the build profile of the project (as seen by an environment) with the versions locked
+// in its lock file. Dependencies which can not be added to a profile are returned as warnings.
+func SketchProfileOf(details *ProjectDetails, lock *ProjectLock) (*SketchProfile, []string, error) {
+	board := details.Board
+	if board == nil || board.Package == "" || board.Architecture == "" {
+		return nil, nil, errors.New(fmt.Sprintf("no board is set in %s", details.FileName()))
+	}
+	fqbn, err := board.FQBN()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !lock.BoardLocked(board) {
+		return nil, nil, errors.New(fmt.Sprintf("the board core package is not locked in %s, please run 'apm install' first", details.LockFileName()))
+	}
+	lockedDeps, ok := lock.LockedDependencies(details)
+	if !ok {
+		return nil, nil, errors.New(fmt.Sprintf("%s does not match %s, please run 'apm install' first", details.LockFileName(), details.FileName()))
+	}
+
+	if !lock.PlatformsLocked(board) {
+		return nil, nil, errors.New(fmt.Sprintf("the platforms of the board are not locked in %s, please run 'apm install' first", details.LockFileName()))
+	}
+
+	// the platform of the board is the first one, the index URL of the other platforms can not be told apart
+	var warnings []string
+	indexUrl := ""
+	if urls := board.IndexUrls(); len(urls) > 0 {
+		indexUrl = urls[0]
+		if len(urls) > 1 {
+			warnings = append(warnings, fmt.Sprintf("only the first board manager URL is added to the profile, the others are: %s", strings.Join(urls[1:], ", ")))
+		}
+	}
+	profile := &SketchProfile{
+		Notes: fmt.Sprintf("generated from %s by apm", details.FileName()),
+		Fqbn:  fqbn,
+		Platforms: []SketchPlatform{{
+			Platform:         fmt.Sprintf("%s:%s (%s)", lock.Board.Package, lock.Board.Architecture, lock.Board.Version),
+			PlatformIndexUrl: indexUrl,
+		}},
+	}
+	for _, platform := range board.Platforms {
+		locked := lock.LockedPlatform(platform)
+		profile.Platforms = append(profile.Platforms, SketchPlatform{Platform: fmt.Sprintf("%s (%s)", platform.ID(), locked.Version)})
+	}
+	if len(board.BuildProperties) > 0 || len(board.Defines) > 0 {
+		warnings = append(warnings, "build properties and defines can not be added to a profile")
+	}
+	for _, locked := range lockedDeps {
+		switch {
+		case locked.Library != "":
+			profile.Libraries = append(profile.Libraries, fmt.Sprintf("%s (%s)", locked.Library, locked.Version))
+		case locked.Git != "":
+			warnings = append(warnings, fmt.Sprintf("'%s' is a git dependency, only libraries of the library index can be added to a profile", locked.Git))
+		case locked.Zip != "":
+			warnings = append(warnings, fmt.Sprintf("'%s' is a zip dependency, only libraries of the library index can be added to a profile", locked.Zip))
+		}
+	}
+	return profile, warnings, nil
+}
+
+// MarshalSketchYaml adds the profiles to the previous sketch.yaml (if any): profiles with the same name are replaced,
+// other profiles and keys are kept. The first profile becomes the default profile if there is none.
+func MarshalSketchYaml(previous []byte, names []string, profiles map[string]*SketchProfile) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	if len(bytes.TrimSpace(previous)) > 0 {
+		var document yaml.Node
+		err := yaml.Unmarshal(previous, &document)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", SketchYamlFileName, err))
+		}
+		if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+			return nil, errors.New(fmt.Sprintf("%s: the document is not a mapping", SketchYamlFileName))
+		}
+		root = document.Content[0]
+	}
+	profilesNode := yamlMappingValue(root, "profiles")
+	if profilesNode == nil {
+		profilesNode = &yaml.Node{Kind: yaml.MappingNode}
+		setYamlMappingValue(root, "profiles", profilesNode)
+	}
+	for _, name := range names {
+		profileData, err := yaml.Marshal(profiles[name])
+		if err != nil {
+			return nil, err
+		}
+		var profileNode yaml.Node
+		err = yaml.Unmarshal(profileData, &profileNode)
+		if err != nil {
+			return nil, err
+		}
+		setYamlMappingValue(profilesNode, name, profileNode.Content[0])
+	}
+	if yamlMappingValue(root, "default_profile") == nil && len(names) > 0 {
+		setYamlMappingValue(root, "default_profile", &yaml.Node{Kind: yaml.ScalarNode, Value: names[0]})
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err := encoder.Encode(root)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	return buffer.Bytes(), err
+}
+
+// ImportSketchYaml converts the profiles of a sketch.yaml into the project details of apm: the default profile becomes
+// the project, if there are more profiles every one of them becomes an environment. Everything which can not be
+// translated is returned as a list of messages.
+func ImportSketchYaml(path string) (*ProjectDetails, []string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var document yaml.Node
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("%s: %s", path, err))
+	}
+	var profilesNode *yaml.Node
+	defaultProfile := ""
+	if len(document.Content) > 0 {
+		profilesNode = yamlMappingValue(document.Content[0], "profiles")
+		if value := yamlMappingValue(document.Content[0], "default_profile"); value != nil {
+			defaultProfile = value.Value
+		}
+	}
+	if profilesNode == nil || profilesNode.Kind != yaml.MappingNode || len(profilesNode.Content) == 0 {
+		return nil, nil, errors.New(fmt.Sprintf("no profiles found in %s", path))
+	}
+
+	var names []string
+	var untranslated []string
+	boards := make(map[string]*ProjectBoard)
+	deps := make(map[string][]ProjectDependency)
+	for i := 0; i+1 < len(profilesNode.Content); i += 2 {
+		name := profilesNode.Content[i].Value
+		if name == defaultProfile {
+			names = append([]string{name}, names...)
+		} else {
+			names = append(names, name)
+		}
+		var keys map[string]interface{}
+		err = profilesNode.Content[i+1].Decode(&keys)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("%s: profile '%s': %s", path, name, err))
+		}
+		var unknownKeys []string
+		for key := range keys {
+			if !sketchProfileKeys[key] {
+				unknownKeys = append(unknownKeys, key)
+			}
+		}
+		sort.Strings(unknownKeys)
+		for _, key := range unknownKeys {
+			untranslated = append(untranslated, fmt.Sprintf("[%s] %s", name, key))
+		}
+		var profile SketchProfile
+		err = profilesNode.Content[i+1].Decode(&profile)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("%s: profile '%s': %s", path, name, err))
+		}
+		board, messages := profile.board()
+		for _, message := range messages {
+			untranslated = append(untranslated, fmt.Sprintf("[%s] %s", name, message))
+		}
+		boards[name] = board
+		deps[name], messages = profile.dependencies()
+		for _, message := range messages {
+			untranslated = append(untranslated, fmt.Sprintf("[%s] %s", name, message))
+		}
+	}
+	return DetailsOfEnvironments(names, boards, deps), untranslated, nil
+}
+
+// board returns the board of a profile with the version and index URL of its platform, the other platforms of the profile
+// become the additional platforms of the board
+func (p *SketchProfile) board() (*ProjectBoard, []string) {
+	var messages []string
+	parts := strings.SplitN(p.Fqbn, ":", 4)
+	if len(parts) < 3 {
+		return &ProjectBoard{}, append(messages, fmt.Sprintf("invalid fqbn '%s'", p.Fqbn))
+	}
+	board := &ProjectBoard{Package: parts[0], Architecture: parts[1], Board: parts[2], Version: "latest"}
+	if len(parts) == 4 {
+		board.Options = make(map[string]string)
+		for _, option := range strings.Split(parts[3], ",") {
+			keyValue := strings.SplitN(option, "=", 2)
+			if len(keyValue) != 2 {
+				messages = append(messages, fmt.Sprintf("invalid board option '%s'", option))
+				continue
+			}
+			board.Options[keyValue[0]] = keyValue[1]
+		}
+	}
+	found := false
+	for _, platform := range p.Platforms {
+		id, version := splitSketchYamlEntry(platform.Platform)
+		if version == "" {
+			version = "latest"
+		}
+		if id == fmt.Sprintf("%s:%s", board.Package, board.Architecture) {
+			found = true
+			board.Version = version
+			// the board manager URL of the core package is the first one
+			if platform.PlatformIndexUrl != "" {
+				board.BoardManagerUrls = append([]string{platform.PlatformIndexUrl}, board.BoardManagerUrls...)
+			}
+			continue
+		}
+		idParts := strings.SplitN(id, ":", 2)
+		if len(idParts) != 2 {
+			messages = append(messages, fmt.Sprintf("invalid platform '%s'", platform.Platform))
+			continue
+		}
+		board.Platforms = append(board.Platforms, ProjectPlatform{Package: idParts[0], Architecture: idParts[1], Version: version})
+		if platform.PlatformIndexUrl != "" {
+			board.BoardManagerUrls = append(board.BoardManagerUrls, platform.PlatformIndexUrl)
+		}
+	}
+	if !found {
+		messages = append(messages, fmt.Sprintf("the platform of the board is not pinned, latest %s:%s is used", board.Package, board.Architecture))
+	}
+	return board, messages
+}
+
+// dependencies returns the libraries of a profile with their pinned versions
+func (p *SketchProfile) dependencies() ([]ProjectDependency, []string) {
+	var messages []string
+	var result []ProjectDependency
+	for _, library := range p.Libraries {
+		name, version := splitSketchYamlEntry(library)
+		if name == "" {
+			messages = append(messages, fmt.Sprintf("invalid library '%s'", library))
+			continue
+		}
+		if version == "" {
+			version = "latest"
+		}
+		result = append(result, ProjectDependency{Library: name, Version: version})
+	}
+	return result, messages
+}
+
+// splitSketchYamlEntry splits NAME (VERSION) of a platform or library
+func splitSketchYamlEntry(entry string) (string, string) {
+	match := sketchYamlEntryRegex.FindStringSubmatch(entry)
+	if match == nil {
+		return "", ""
+	}
+	return match[1], match[2]
+}
+
+// yamlMappingValue returns the value of a key of a YAML mapping or nil
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setYamlMappingValue replaces the value of a key of a YAML mapping or adds the key
+func setYamlMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
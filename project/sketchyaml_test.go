@@ -0,0 +1,199 @@
+package project
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSketchProfileOf(t *testing.T) {
+	details := &ProjectDetails{
+		Board: &ProjectBoard{
+			Package:          "esp8266",
+			Architecture:     "esp8266",
+			Version:          "^3.0.0",
+			Board:            "nodemcuv2",
+			Options:          map[string]string{"xtal": "160", "baud": "921600"},
+			BoardManagerUrls: []string{"https://example.com/esp8266.json", "https://example.com/other.json"},
+			Platforms:        []ProjectPlatform{{Package: "other", Architecture: "tools", Version: "latest"}},
+		},
+		Dependencies: []ProjectDependency{
+			{Library: "DHT sensor library", Version: "^1.4.0"},
+			{Git: "https://github.com/example/sensor.git"},
+		},
+	}
+	lock := &ProjectLock{
+		Board:     &LockedBoard{Package: "esp8266", Architecture: "esp8266", Version: "3.0.2"},
+		Platforms: []LockedBoard{{Package: "other", Architecture: "tools", Version: "1.0.0"}},
+		Dependencies: []LockedDependency{
+			{Library: "DHT sensor library", Version: "1.4.3", Dependencies: []string{"Adafruit Unified Sensor"}},
+			{Library: "Adafruit Unified Sensor", Version: "1.1.5"},
+			{Git: "https://github.com/example/sensor.git", Commit: "0123456789abcdef"},
+		},
+	}
+
+	profile, warnings, err := SketchProfileOf(details, lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &SketchProfile{
+		Notes: "generated from apm.json by apm",
+		Fqbn:  "esp8266:esp8266:nodemcuv2:baud=921600,xtal=160",
+		Platforms: []SketchPlatform{
+			{Platform: "esp8266:esp8266 (3.0.2)", PlatformIndexUrl: "https://example.com/esp8266.json"},
+			{Platform: "other:tools (1.0.0)"},
+		},
+		Libraries: []string{"DHT sensor library (1.4.3)", "Adafruit Unified Sensor (1.1.5)"},
+	}
+	if !reflect.DeepEqual(profile, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", profile, want)
+	}
+	wantWarnings := []string{
+		"only the first board manager URL is added to the profile, the others are: https://example.com/other.json",
+		"'https://github.com/example/sensor.git' is a git dependency, only libraries of the library index can be added to a profile",
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings:\n%q\nwant:\n%q", warnings, wantWarnings)
+	}
+
+	// the versions must be locked
+	details.Dependencies = append(details.Dependencies, ProjectDependency{Library: "Servo", Version: "1.8.3"})
+	if _, _, err := SketchProfileOf(details, lock); err == nil {
+		t.Errorf("no error for an unlocked dependency")
+	}
+	lock.Board.Version = "2.7.4"
+	if _, _, err := SketchProfileOf(details, lock); err == nil {
+		t.Errorf("no error for an unlocked board")
+	}
+}
+
+func TestMarshalSketchYaml(t *testing.T) {
+	profiles := map[string]*SketchProfile{
+		"uno": {
+			Fqbn:      "arduino:avr:uno",
+			Platforms: []SketchPlatform{{Platform: "arduino:avr (1.8.3)"}},
+			Libraries: []string{"Servo (1.1.8)"},
+		},
+	}
+	tests := []struct {
+		name     string
+		previous string
+		want     string
+	}{
+		{
+			name: "new file",
+			want: `profiles:
+  uno:
+    fqbn: arduino:avr:uno
+    platforms:
+    - platform: arduino:avr (1.8.3)
+    libraries:
+    - Servo (1.1.8)
+default_profile: uno
+`,
+		},
+		{
+			name: "other profiles and keys are kept",
+			previous: `default_profile: nano
+profiles:
+  nano:
+    fqbn: arduino:avr:nano
+    platforms:
+    - platform: arduino:avr (1.8.3)
+  uno:
+    fqbn: arduino:avr:uno
+    platforms:
+    - platform: arduino:avr (1.8.2)
+`,
+			want: `default_profile: nano
+profiles:
+  nano:
+    fqbn: arduino:avr:nano
+    platforms:
+    - platform: arduino:avr (1.8.3)
+  uno:
+    fqbn: arduino:avr:uno
+    platforms:
+    - platform: arduino:avr (1.8.3)
+    libraries:
+    - Servo (1.1.8)
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := MarshalSketchYaml([]byte(test.previous), []string{"uno"}, profiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("got:\n%s\nwant:\n%s", data, test.want)
+			}
+		})
+	}
+}
+
+func TestImportSketchYaml(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apm-sketchyaml-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, SketchYamlFileName)
+	input := `profiles:
+  nano:
+    fqbn: arduino:avr:nano:cpu=atmega328old
+    platforms:
+      - platform: arduino:avr (1.8.3)
+    libraries:
+      - Servo (1.1.8)
+  esp:
+    notes: the station
+    fqbn: esp8266:esp8266:nodemcuv2
+    platforms:
+      - platform: esp8266:esp8266 (3.0.2)
+        platform_index_url: https://example.com/esp8266.json
+    libraries:
+      - Servo (1.1.8)
+      - DHT sensor library
+    port: /dev/ttyUSB0
+default_profile: esp
+`
+	if err := ioutil.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	details, untranslated, err := ImportSketchYaml(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	esp := &ProjectBoard{
+		Package:          "esp8266",
+		Architecture:     "esp8266",
+		Version:          "3.0.2",
+		BoardManagerUrls: []string{"https://example.com/esp8266.json"},
+		Board:            "nodemcuv2",
+	}
+	want := &ProjectDetails{
+		Board:        esp,
+		Dependencies: []ProjectDependency{{Library: "Servo", Version: "1.1.8"}},
+		Environments: map[string]*ProjectEnvironment{
+			"esp": {Dependencies: []ProjectDependency{{Library: "DHT sensor library", Version: "latest"}}},
+			"nano": {Board: &ProjectBoard{
+				Package:      "arduino",
+				Architecture: "avr",
+				Version:      "1.8.3",
+				Board:        "nano",
+				Options:      map[string]string{"cpu": "atmega328old"},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(details, want) {
+		t.Errorf("got:\n%+v\nwant:\n%+v", details, want)
+	}
+	if wantUntranslated := []string{"[esp] port"}; !reflect.DeepEqual(untranslated, wantUntranslated) {
+		t.Errorf("untranslated %q, want %q", untranslated, wantUntranslated)
+	}
+}
@@ -0,0 +1,66 @@
+package project
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/ksrichard/apm/util"
+	"github.com/spf13/cobra"
+)
+
+// FilesSnapshot keeps the project file and the lock file as they were before a change, so they can be restored
+type FilesSnapshot struct {
+	// contents of the files by their paths, nil if the file did not exist
+	files map[string][]byte
+}
+
+// SnapshotFiles saves the project file and the lock file of the environment selected with --env
+func SnapshotFiles(cmd *cobra.Command) (*FilesSnapshot, error) {
+	projectDir, err := GetProjectDir(cmd)
+	if err != nil {
+		return nil, err
+	}
+	projectFile, err := FindProjectFile(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	lockFile, err := GetProjectLockFile(cmd)
+	if err != nil {
+		return nil, err
+	}
+	result := &FilesSnapshot{files: make(map[string][]byte)}
+	for _, file := range []string{projectFile, lockFile} {
+		if file == "" {
+			continue
+		}
+		result.files[file] = nil
+		if util.FileExists(file) {
+			fileData, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			result.files[file] = fileData
+		}
+	}
+	return result, nil
+}
+
+// Restore writes back the saved files and removes the ones which did not exist
+func (s *FilesSnapshot) Restore() error {
+	for file, fileData := range s.files {
+		if fileData == nil {
+			if util.FileExists(file) {
+				err := os.Remove(file)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		err := util.WriteFileAtomic(file, fileData, os.ModePerm)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,20 +1,112 @@
 package project
 
 type ProjectDetails struct {
+	// schema version of the project file, older project files are migrated by 'apm migrate'
+	SchemaVersion int                  `json:"schema_version,omitempty"`
 	Board        *ProjectBoard        `json:"board"`
 	Dependencies []ProjectDependency `json:"dependencies"`
+	// libraries only needed for development (e.g. by test sketches), the same as the "dev" dependency group
+	DevDependencies []ProjectDependency `json:"dev_dependencies,omitempty"`
+	// named groups of dependencies installed together with the dependencies, optional groups only with --with
+	Groups map[string]*DependencyGroup `json:"groups,omitempty"`
+	// install libraries and cores into the project local .apm directory instead of the global sketchbook
+	Isolation bool `json:"isolation,omitempty"`
+	// directory of the vendored libraries (relative to the project), installed instead of downloading them
+	Vendor string `json:"vendor,omitempty"`
+	// named build environments with their own board and additional dependencies
+	Environments map[string]*ProjectEnvironment `json:"environments,omitempty"`
+	// directory of the project, it is not stored in apm.json
+	Dir string `json:"-"`
+	// name of the selected environment, it is not stored in apm.json
+	Environment string `json:"-"`
+	// names of the dependency groups added to the dependencies, it is not stored in apm.json
+	SelectedGroups []string `json:"-"`
+	// parsed project file, used to report the positions of problems
+	document *Document
+}
+
+type ProjectEnvironment struct {
+	Board        *ProjectBoard       `json:"board,omitempty"`
+	Dependencies []ProjectDependency `json:"dependencies,omitempty"`
+}
+
+type DependencyGroup struct {
+	// optional groups are only installed if they are selected with --with
+	Optional     bool                `json:"optional,omitempty"`
+	Dependencies []ProjectDependency `json:"dependencies"`
 }
 
 type ProjectBoard struct {
 	Package      string `json:"package,omitempty"`
 	Architecture string `json:"architecture,omitempty"`
 	Version      string `json:"version,omitempty"`
-	BoardManagerUrl string `json:"board_manager_url,omitempty"`
+	// board manager URLs of the core package and of the additional platforms
+	BoardManagerUrls []string `json:"board_manager_urls,omitempty"`
+	// board ID in the core package (e.g. nodemcuv2) or the full FQBN (e.g. esp8266:esp8266:nodemcuv2) used to build the project
+	Board string `json:"board,omitempty"`
+	Fqbn  string `json:"fqbn,omitempty"`
+	// board menu options (e.g. PartitionScheme: min_spiffs) appended to the FQBN
+	Options map[string]string `json:"options,omitempty"`
+	// build properties (e.g. build.extra_flags=-DDEBUG) and preprocessor defines (e.g. DEBUG or LED_PIN=2) used by every compile
+	BuildProperties []string `json:"build_properties,omitempty"`
+	Defines         []string `json:"defines,omitempty"`
+	Build *BuildOptions `json:"build,omitempty"`
+	// additional platforms needed by the board (e.g. arduino:avr for a core which uses its tools), installed before its core
+	Platforms []ProjectPlatform `json:"platforms,omitempty"`
+}
+
+type ProjectPlatform struct {
+	Package      string `json:"package"`
+	Architecture string `json:"architecture"`
+	Version      string `json:"version"`
+}
+
+type BuildOptions struct {
+	// directory of the compiled binaries (relative to the project), build by default
+	OutputDir string `json:"output_dir,omitempty"`
+	// compiler warnings: none, default, more or all
+	Warnings         string `json:"warnings,omitempty"`
+	OptimizeForDebug bool   `json:"optimize_for_debug,omitempty"`
+	Verbose          bool   `json:"verbose,omitempty"`
+	Jobs             int32  `json:"jobs,omitempty"`
 }
 
 type ProjectDependency struct {
 	Library string `json:"library,omitempty"`
 	Version string `json:"version,omitempty"`
 	Git     string `json:"git,omitempty"`
+	// branch, tag or commit of the git repository, the default branch is used if empty
+	Ref string `json:"ref,omitempty"`
+	Zip string `json:"zip,omitempty"`
+	// expected sha256 of the zip file or of the sources of the git repository
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+type ProjectLock struct {
+	Board *LockedBoard `json:"board,omitempty"`
+	// additional platforms of the board
+	Platforms    []LockedBoard      `json:"platforms,omitempty"`
+	Dependencies []LockedDependency `json:"dependencies"`
+}
+
+type LockedBoard struct {
+	Package      string `json:"package"`
+	Architecture string `json:"architecture"`
+	Version      string `json:"version"`
+}
+
+type LockedDependency struct {
+	Library string `json:"library,omitempty"`
+	Version string `json:"version,omitempty"`
+	Git     string `json:"git,omitempty"`
+	Ref     string `json:"ref,omitempty"`
+	Commit  string `json:"commit,omitempty"`
 	Zip     string `json:"zip,omitempty"`
+	Sha256  string `json:"sha256,omitempty"`
+	// sha256 of the installed library directory
+	Hash string `json:"hash,omitempty"`
+	// name of the installed library directory
+	Dir string `json:"dir,omitempty"`
+	// names of the libraries this library depends on
+	Dependencies []string `json:"dependencies,omitempty"`
 }
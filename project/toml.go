@@ -0,0 +1,603 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+var bareTomlKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func parseTomlDocument(file string, data []byte) (*Document, error) {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", file, err))
+	}
+	root := tomlTreeToNode(tree)
+	root.Line, root.Column = 1, 1
+	result := &Document{File: file, Format: FormatToml, data: data, root: root}
+	// only for the positions and styles of the inline values, the comments are read when the project file is rewritten
+	tomlComments(result)
+	return result, nil
+}
+
+// tomlTreeToNode converts a TOML table to the node of a project file, the fields are ordered by their positions
+func tomlTreeToNode(tree *toml.Tree) *jsonNode {
+	result := &jsonNode{Kind: "object", Line: tree.Position().Line, Column: tree.Position().Col}
+	keys := tree.Keys()
+	sort.Slice(keys, func(i, j int) bool {
+		a := tree.GetPositionPath([]string{keys[i]})
+		b := tree.GetPositionPath([]string{keys[j]})
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Col != b.Col {
+			return a.Col < b.Col
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		position := tree.GetPositionPath([]string{key})
+		value := tomlValueToNode(tree.GetPath([]string{key}), position)
+		result.Fields = append(result.Fields, &jsonField{Key: key, KeyLine: position.Line, KeyColumn: position.Col, Value: value})
+	}
+	return result
+}
+
+func tomlValueToNode(value interface{}, position toml.Position) *jsonNode {
+	result := &jsonNode{Line: position.Line, Column: position.Col}
+	switch v := value.(type) {
+	case *toml.Tree:
+		return tomlTreeToNode(v)
+	case []*toml.Tree:
+		result.Kind = "array"
+		for _, item := range v {
+			result.Items = append(result.Items, tomlTreeToNode(item))
+		}
+	case []interface{}:
+		result.Kind = "array"
+		for _, item := range v {
+			result.Items = append(result.Items, tomlValueToNode(item, position))
+		}
+	case string:
+		result.Kind = "string"
+		result.Value = v
+	case int64:
+		result.Kind = "number"
+		result.Value = json.Number(strconv.FormatInt(v, 10))
+	case float64:
+		result.Kind = "number"
+		result.Value = json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		result.Kind = "boolean"
+		result.Value = v
+	case time.Time:
+		result.Kind = "string"
+		result.Value = v.Format(time.RFC3339Nano)
+	default:
+		result.Kind = "string"
+		result.Value = fmt.Sprint(v)
+	}
+	return result
+}
+
+// tomlComments returns the comments of a TOML project file. The file is read line by line: comment lines belong to
+// the next key or table header, comments after a value belong to the value and comments at the end to the document.
+// Values written as inline arrays and tables get their style and their position, which go-toml does not report.
+func tomlComments(document *Document) (comments, error) {
+	result := comments{}
+	var pending []string
+	var table []string
+	tableNode := document.root
+	tableCounts := make(map[string]int)
+	// tables with fields which got their positions
+	positioned := make(map[*jsonNode]bool)
+	lines := strings.Split(string(document.data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == "":
+			// comments at the beginning of the file separated by an empty line belong to the document
+			if len(result) == 0 && len(pending) > 0 {
+				result.add(nil, strings.Join(pending, "\n"), "", "")
+				pending = nil
+			}
+			continue
+		case strings.HasPrefix(line, "#"):
+			pending = append(pending, line)
+			continue
+		case strings.HasPrefix(line, "[["):
+			end := indexOutsideStrings(line, "]]")
+			if end < 0 {
+				return nil, errors.New(fmt.Sprintf("%s:%d: invalid table header", document.File, i+1))
+			}
+			header := splitTomlKey(line[2:end])
+			index := tableCounts[commentPath(header)]
+			tableCounts[commentPath(header)]++
+			identity := fmt.Sprintf("[%d]", index)
+			tableNode = nil
+			if array := document.lookup(stringsToPath(header)...); array != nil && array.Kind == "array" && index < len(array.Items) {
+				identity = itemIdentity(array.Items[index], index)
+				tableNode = array.Items[index]
+			}
+			table = appendString(header, identity)
+			result.add(table, strings.Join(pending, "\n"), trailingTomlComment(line[end+2:]), "")
+		case strings.HasPrefix(line, "["):
+			end := indexOutsideStrings(line, "]")
+			if end < 0 {
+				return nil, errors.New(fmt.Sprintf("%s:%d: invalid table header", document.File, i+1))
+			}
+			table = splitTomlKey(line[1:end])
+			tableNode = document.lookup(stringsToPath(table)...)
+			result.add(table, strings.Join(pending, "\n"), trailingTomlComment(line[end+1:]), "")
+		default:
+			equals := indexOutsideStrings(line, "=")
+			if equals < 0 {
+				return nil, errors.New(fmt.Sprintf("%s:%d: invalid key/value pair", document.File, i+1))
+			}
+			key := splitTomlKey(line[:equals])
+			// arrays, inline tables and multi-line strings can continue in the next lines
+			start := i
+			state := &tomlScanState{}
+			last := line[equals+1:]
+			commentIndex := state.scanValue(last)
+			for (state.depth > 0 || state.quote != "") && i+1 < len(lines) {
+				i++
+				last = lines[i]
+				commentIndex = state.scanValue(last)
+			}
+			comment := ""
+			if commentIndex >= 0 {
+				comment = strings.TrimSpace(last[commentIndex:])
+			}
+			result.add(append(append([]string{}, table...), key...), strings.Join(pending, "\n"), comment, "")
+			if parent, field := tomlField(tableNode, key); field != nil {
+				markTomlValue(field, lines[start], start+1, i > start)
+				positioned[parent] = true
+			}
+		}
+		pending = nil
+	}
+	result.add(nil, "", "", strings.Join(pending, "\n"))
+	for table := range positioned {
+		sort.SliceStable(table.Fields, func(i, j int) bool {
+			a, b := table.Fields[i], table.Fields[j]
+			return a.KeyLine < b.KeyLine || (a.KeyLine == b.KeyLine && a.KeyColumn < b.KeyColumn)
+		})
+	}
+	return result, nil
+}
+
+func stringsToPath(keys []string) []interface{} {
+	var result []interface{}
+	for _, key := range keys {
+		result = append(result, key)
+	}
+	return result
+}
+
+// tomlField returns the field of a (dotted) key in a table and the table which contains it
+func tomlField(table *jsonNode, key []string) (*jsonNode, *jsonField) {
+	for i, part := range key {
+		if table == nil || table.Kind != "object" {
+			return nil, nil
+		}
+		var found *jsonField
+		for _, field := range table.Fields {
+			if field.Key == part {
+				found = field
+			}
+		}
+		if found == nil {
+			return nil, nil
+		}
+		if i == len(key)-1 {
+			return table, found
+		}
+		table = found.Value
+	}
+	return nil, nil
+}
+
+// markTomlValue sets the position of a key/value pair if it is not set and the style of its value
+func markTomlValue(field *jsonField, line string, lineNumber int, multiline bool) {
+	equals := indexOutsideStrings(line, "=")
+	value := strings.TrimLeft(line[equals+1:], " \t")
+	if field.KeyLine == 0 {
+		field.KeyLine = lineNumber
+		field.KeyColumn = len(line) - len(strings.TrimLeft(line, " \t")) + 1
+	}
+	if field.Value.Line == 0 {
+		setTomlPosition(field.Value, lineNumber, len(line)-len(value)+1)
+	}
+	switch field.Value.Kind {
+	case "object", "array":
+		field.Value.Style = styleFlow
+		if multiline {
+			field.Value.Style = styleMultiline
+		}
+	case "string":
+		if strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''") {
+			field.Value.Style = styleLiteral
+		} else if strings.HasPrefix(value, "'") {
+			field.Value.Style = styleSingleQuoted
+		}
+	}
+}
+
+// setTomlPosition sets the position of the values of an inline array or table which have no position
+func setTomlPosition(node *jsonNode, line int, column int) {
+	node.Line, node.Column = line, column
+	for _, field := range node.Fields {
+		if field.KeyLine == 0 {
+			field.KeyLine, field.KeyColumn = line, column
+		}
+		if field.Value.Line == 0 {
+			setTomlPosition(field.Value, line, column)
+		}
+	}
+	for _, item := range node.Items {
+		if item.Line == 0 {
+			setTomlPosition(item, line, column)
+		}
+	}
+}
+
+// tomlScanState is the state of scanning a TOML value which can continue in the next lines
+type tomlScanState struct {
+	// delimiter of the string being scanned
+	quote string
+	// depth of the arrays and inline tables
+	depth int
+}
+
+// scan calls the function for every character of a line which is not in a string or comment, the scan stops when
+// the function returns false. The index of the comment of the line is returned or -1.
+func (s *tomlScanState) scan(line string, f func(i int) bool) int {
+	for i := 0; i < len(line); i++ {
+		if s.quote != "" {
+			if line[i] == '\\' && s.quote[0] == '"' {
+				i++
+			} else if strings.HasPrefix(line[i:], s.quote) {
+				i += len(s.quote) - 1
+				s.quote = ""
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line[i:], `"""`) || strings.HasPrefix(line[i:], "'''"):
+			s.quote = line[i : i+3]
+			i += 2
+		case line[i] == '"' || line[i] == '\'':
+			s.quote = line[i : i+1]
+		case line[i] == '#':
+			return i
+		case !f(i):
+			return -1
+		}
+	}
+	// only multi-line strings continue in the next line
+	if len(s.quote) == 1 {
+		s.quote = ""
+	}
+	return -1
+}
+
+// scanValue scans a line of a value and returns the index of its comment or -1
+func (s *tomlScanState) scanValue(line string) int {
+	return s.scan(line, func(i int) bool {
+		switch line[i] {
+		case '[', '{':
+			s.depth++
+		case ']', '}':
+			s.depth--
+		}
+		return true
+	})
+}
+
+// scanTomlLine calls the function for every character of a line which is not in a string or comment,
+// the scan stops when the function returns false. The index of the comment of the line is returned or -1.
+func scanTomlLine(line string, f func(i int) bool) int {
+	return (&tomlScanState{}).scan(line, f)
+}
+
+func indexOutsideStrings(line string, value string) int {
+	result := -1
+	scanTomlLine(line, func(i int) bool {
+		if strings.HasPrefix(line[i:], value) {
+			result = i
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+// trailingTomlComment returns the comment at the end of a line
+func trailingTomlComment(line string) string {
+	if i := scanTomlLine(line, func(i int) bool { return true }); i >= 0 {
+		return strings.TrimSpace(line[i:])
+	}
+	return ""
+}
+
+// splitTomlKey splits a dotted key (e.g. environments."esp32.dev".board) into its parts
+func splitTomlKey(key string) []string {
+	var result []string
+	start := 0
+	split := func(end int) {
+		part := strings.TrimSpace(key[start:end])
+		if unquoted, err := strconv.Unquote(part); err == nil && strings.HasPrefix(part, "\"") {
+			part = unquoted
+		} else if len(part) >= 2 && strings.HasPrefix(part, "'") && strings.HasSuffix(part, "'") {
+			part = part[1 : len(part)-1]
+		}
+		result = append(result, part)
+		start = end + 1
+	}
+	scanTomlLine(key, func(i int) bool {
+		if key[i] == '.' {
+			split(i)
+		}
+		return true
+	})
+	split(len(key))
+	return result
+}
+
+// marshalToml returns the TOML project file of a merged project file with the given comments, inline arrays and tables
+// of the previous TOML project file (nil if there is none) are kept inline
+func marshalToml(root *jsonNode, projectComments comments, m *merger, previous *Document) []byte {
+	writer := &tomlWriter{comments: projectComments, merger: m}
+	if previous != nil {
+		writer.lines = strings.Split(string(previous.data), "\n")
+	}
+	documentComment := projectComments.get(nil)
+	if documentComment.Head != "" {
+		writer.buffer.WriteString(documentComment.Head + "\n\n")
+	}
+	writer.writeTable(root, m.styleOf(root, nil), nil, nil, false)
+	if documentComment.Foot != "" {
+		writer.buffer.WriteString("\n" + documentComment.Foot + "\n")
+	}
+	return writer.buffer.Bytes()
+}
+
+type tomlWriter struct {
+	buffer   bytes.Buffer
+	comments comments
+	merger   *merger
+	// lines of the previous TOML project file
+	lines []string
+}
+
+// writeTable writes the values of an object and then its tables, path is the path of the comments of the object
+// and source is the node of the previous project file with the style of the object
+func (w *tomlWriter) writeTable(node *jsonNode, source *jsonNode, path []string, header []string, arrayItem bool) {
+	// tables which only contain tables are not written (e.g. [environments] before [environments.esp32.board])
+	c := w.comments.get(path)
+	implicit := len(node.Fields) > 0 && c.Head == "" && c.Line == "" && !arrayItem
+	for _, field := range node.Fields {
+		implicit = implicit && w.isTable(field.Value, w.merger.styleOf(field.Value, source.field(field.Key)))
+	}
+	if header != nil && !implicit {
+		if w.buffer.Len() > 0 {
+			w.buffer.WriteString("\n")
+		}
+		w.writeComment(c.Head)
+		if arrayItem {
+			w.buffer.WriteString("[[" + tomlKey(header) + "]]")
+		} else {
+			w.buffer.WriteString("[" + tomlKey(header) + "]")
+		}
+		w.writeLineComment(c.Line)
+	}
+	for _, field := range node.Fields {
+		fieldSource := w.merger.styleOf(field.Value, source.field(field.Key))
+		if w.isTable(field.Value, fieldSource) || field.Value.Kind == "null" {
+			continue
+		}
+		c := w.comments.get(appendString(path, field.Key))
+		w.writeComment(c.Head)
+		w.buffer.WriteString(tomlKey([]string{field.Key}) + " = " + w.value(field.Value, fieldSource))
+		w.writeLineComment(c.Line)
+		w.writeComment(c.Foot)
+	}
+	for _, field := range node.Fields {
+		fieldSource := w.merger.styleOf(field.Value, source.field(field.Key))
+		if !w.isTable(field.Value, fieldSource) {
+			continue
+		}
+		if field.Value.Kind == "object" {
+			w.writeTable(field.Value, fieldSource, appendString(path, field.Key), appendString(header, field.Key), false)
+			continue
+		}
+		for i, item := range field.Value.Items {
+			w.writeTable(item, w.merger.styleOf(item, nil), appendString(appendString(path, field.Key), itemIdentity(item, i)), appendString(header, field.Key), true)
+		}
+	}
+	if header != nil {
+		w.writeComment(c.Foot)
+	}
+}
+
+func (w *tomlWriter) writeComment(comment string) {
+	if comment != "" {
+		w.buffer.WriteString(comment + "\n")
+	}
+}
+
+func (w *tomlWriter) writeLineComment(comment string) {
+	if comment != "" {
+		w.buffer.WriteString(" " + strings.Replace(comment, "\n", " ", -1))
+	}
+	w.buffer.WriteString("\n")
+}
+
+// isTable returns true if the value is written as a table or an array of tables, values which were inline arrays
+// or tables in the previous project file are kept inline
+func (w *tomlWriter) isTable(node *jsonNode, source *jsonNode) bool {
+	return isTomlTable(node) && (source == nil || (source.Style != styleFlow && source.Style != styleMultiline))
+}
+
+// isTomlTable returns true if the value can be written as a table or an array of tables
+func isTomlTable(node *jsonNode) bool {
+	if node.Kind == "object" {
+		return true
+	}
+	if node.Kind != "array" || len(node.Items) == 0 {
+		return false
+	}
+	for _, item := range node.Items {
+		if item.Kind != "object" {
+			return false
+		}
+	}
+	return true
+}
+
+// value returns a value as it is written after its key, arrays which were written in more lines in the previous
+// project file are written in more lines again
+func (w *tomlWriter) value(node *jsonNode, source *jsonNode) string {
+	switch node.Kind {
+	case "string":
+		value := node.Value.(string)
+		if source != nil && source.Style == styleSingleQuoted && !strings.ContainsAny(value, "'\r\n") {
+			return "'" + value + "'"
+		}
+		if source != nil && source.Style == styleLiteral && strings.Contains(value, "\n") {
+			return tomlMultilineString(value)
+		}
+		return tomlString(value)
+	case "array":
+		var items []string
+		for _, item := range node.Items {
+			items = append(items, w.value(item, w.merger.styleOf(item, nil)))
+		}
+		if source != nil && source.Style == styleMultiline && len(items) > 0 {
+			indent, comma := w.multilineLayout(source)
+			return "[\n" + indent + strings.Join(items, ",\n"+indent) + comma + "\n]"
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case "object":
+		var fields []string
+		for _, field := range node.Fields {
+			fields = append(fields, tomlKey([]string{field.Key})+" = "+w.value(field.Value, w.merger.styleOf(field.Value, source.field(field.Key))))
+		}
+		if len(fields) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(fields, ", ") + " }"
+	case "null":
+		return "\"\""
+	}
+	return fmt.Sprint(node.Value)
+}
+
+// multilineLayout returns the indentation of the items of an array written in more lines in the previous project file
+// and the comma after its last item
+func (w *tomlWriter) multilineLayout(source *jsonNode) (string, string) {
+	indent, comma := "    ", ","
+	if source.Line < 1 || source.Line > len(w.lines) {
+		return indent, comma
+	}
+	line := w.lines[source.Line-1]
+	state := &tomlScanState{}
+	state.scanValue(line[indexOutsideStrings(line, "=")+1:])
+	found, last := false, ""
+	for i := source.Line; i < len(w.lines) && state.depth > 0; i++ {
+		line = w.lines[i]
+		if commentIndex := state.scanValue(line); commentIndex >= 0 {
+			line = line[:commentIndex]
+		}
+		trimmed := strings.TrimSpace(line)
+		if state.depth <= 0 {
+			// the last line has the closing bracket
+			if !strings.HasPrefix(trimmed, "]") {
+				last = ""
+			}
+			break
+		}
+		if trimmed != "" {
+			if !found {
+				indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				found = true
+			}
+			last = trimmed
+		}
+	}
+	if !strings.HasSuffix(last, ",") {
+		comma = ""
+	}
+	return indent, comma
+}
+
+func tomlKey(keys []string) string {
+	var parts []string
+	for _, key := range keys {
+		if bareTomlKeyRegex.MatchString(key) {
+			parts = append(parts, key)
+		} else {
+			parts = append(parts, tomlString(key))
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func tomlString(value string) string {
+	var result strings.Builder
+	result.WriteString("\"")
+	for _, c := range value {
+		switch c {
+		case '"':
+			result.WriteString("\\\"")
+		case '\\':
+			result.WriteString("\\\\")
+		case '\n':
+			result.WriteString("\\n")
+		case '\r':
+			result.WriteString("\\r")
+		case '\t':
+			result.WriteString("\\t")
+		default:
+			if c < 0x20 || c == 0x7f {
+				result.WriteString(fmt.Sprintf("\\u%04X", c))
+			} else {
+				result.WriteRune(c)
+			}
+		}
+	}
+	result.WriteString("\"")
+	return result.String()
+}
+
+// tomlMultilineString returns a multi-line basic string, the newline after the opening delimiter is not part of it
+func tomlMultilineString(value string) string {
+	var result strings.Builder
+	result.WriteString(`"""` + "\n")
+	for i, c := range value {
+		switch {
+		case c == '\\':
+			result.WriteString(`\\`)
+		case c == '"' && (strings.HasPrefix(value[i:], `"""`) || i == len(value)-1):
+			result.WriteString(`\"`)
+		case c == '\r':
+			result.WriteString(`\r`)
+		case (c < 0x20 && c != '\n' && c != '\t') || c == 0x7f:
+			result.WriteString(fmt.Sprintf("\\u%04X", c))
+		default:
+			result.WriteRune(c)
+		}
+	}
+	result.WriteString(`"""`)
+	return result.String()
+}
@@ -0,0 +1,153 @@
+package project
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTomlComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]comment
+	}{
+		{
+			name: "document comments",
+			input: `# project of the weather station
+
+# the current schema
+schema_version = 2
+# end of the project
+`,
+			want: map[string]comment{
+				"":               {Head: "# project of the weather station", Foot: "# end of the project"},
+				"schema_version": {Head: "# the current schema"},
+			},
+		},
+		{
+			name: "comments of keys and tables",
+			input: `schema_version = 2 # do not change
+
+# the board of the station
+[board] # uno only
+# from the official index
+package = "arduino"
+architecture = "avr"
+board = "uno" # the "#" is not a comment in strings
+`,
+			want: map[string]comment{
+				"schema_version":   {Line: "# do not change"},
+				"board":            {Head: "# the board of the station", Line: "# uno only"},
+				"board\x00package": {Head: "# from the official index"},
+				"board\x00board":   {Line: `# the "#" is not a comment in strings`},
+			},
+		},
+		{
+			name: "comments of dependencies follow the library",
+			input: `# pinned because of the servo timer conflict
+[[dependencies]]
+library = "Servo"
+version = "1.8.3" # the last working one
+
+[[dependencies]] # optional
+git = "https://github.com/example/sensor.git"
+`,
+			want: map[string]comment{
+				"dependencies\x00[library=Servo]":                             {Head: "# pinned because of the servo timer conflict"},
+				"dependencies\x00[library=Servo]\x00version":                  {Line: "# the last working one"},
+				"dependencies\x00[git=https://github.com/example/sensor.git]": {Line: "# optional"},
+			},
+		},
+		{
+			name: "multi-line values",
+			input: `# the board manager indexes
+board_manager_urls = [
+  "https://example.com/package_index.json",
+] # two of them
+x-notes = """
+# not a comment
+"""
+# after the notes
+schema_version = 2
+`,
+			want: map[string]comment{
+				"board_manager_urls": {Head: "# the board manager indexes", Line: "# two of them"},
+				"schema_version":     {Head: "# after the notes"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			document, err := ParseDocument("apm.toml", []byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := tomlComments(document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]comment)
+			for key, value := range result {
+				got[key] = *value
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got:\n%s\nwant:\n%s", formatComments(got), formatComments(test.want))
+			}
+		})
+	}
+}
+
+func TestTomlInlineValueStyles(t *testing.T) {
+	input := `dependencies = [
+  { library = "Servo", version = "1.8.3" },
+]
+x-notes = '''
+literal
+'''
+
+[board]
+package = 'arduino'
+board_manager_urls = ["https://example.com/package_index.json"]
+`
+	document, err := ParseDocument("apm.toml", []byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  []interface{}
+		style string
+		line  int
+	}{
+		{[]interface{}{"dependencies"}, styleMultiline, 1},
+		{[]interface{}{"x-notes"}, styleLiteral, 4},
+		{[]interface{}{"board", "package"}, styleSingleQuoted, 9},
+		{[]interface{}{"board", "board_manager_urls"}, styleFlow, 10},
+	}
+	for _, test := range tests {
+		node := document.lookup(test.path...)
+		if node == nil {
+			t.Errorf("%s: not found", formatPath(test.path))
+			continue
+		}
+		if node.Style != test.style || node.Line != test.line {
+			t.Errorf("%s: style %q at line %d, want %q at line %d", formatPath(test.path), node.Style, node.Line, test.style, test.line)
+		}
+	}
+	// the fields are in the order of the file although go-toml reports no position for inline values
+	var keys []string
+	for _, field := range document.root.Fields {
+		keys = append(keys, field.Key)
+	}
+	if strings.Join(keys, ",") != "dependencies,x-notes,board" {
+		t.Errorf("keys %v, want [dependencies x-notes board]", keys)
+	}
+}
+
+func formatComments(c map[string]comment) string {
+	var result []string
+	for key, value := range c {
+		result = append(result, strings.Replace(key, "\x00", ".", -1)+": "+strings.Join([]string{value.Head, value.Line, value.Foot}, " | "))
+	}
+	return strings.Join(result, "\n")
+}
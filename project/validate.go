@@ -0,0 +1,388 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ksrichard/apm/util"
+)
+
+// Problem is a problem of the project file found by the validation
+type Problem struct {
+	Position string
+	Path     string
+	Message  string
+	line     int
+	column   int
+}
+
+func (p Problem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%s: %s", p.Position, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Position, p.Path, p.Message)
+}
+
+// FormatProblems returns the problems one per line
+func FormatProblems(problems []Problem) string {
+	var lines []string
+	for _, problem := range problems {
+		lines = append(lines, problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SortProblems sorts the problems by their position in the file
+func SortProblems(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].line != problems[j].line {
+			return problems[i].line < problems[j].line
+		}
+		return problems[i].column < problems[j].column
+	})
+}
+
+// ProblemAt returns a problem at the value of a path (or its nearest existing parent)
+func (d *Document) ProblemAt(message string, path ...interface{}) Problem {
+	result := Problem{Position: d.Position(path...), Path: formatPath(path), Message: message}
+	if d != nil {
+		for i := len(path); i >= 0; i-- {
+			if node := d.lookup(path[:i]...); node != nil {
+				result.line, result.column = node.Line, node.Column
+				break
+			}
+		}
+	}
+	return result
+}
+
+// BoardPath returns the path of the board of the project (or of the selected environment) in the project file
+func (d *ProjectDetails) BoardPath() []interface{} {
+	if d.Environment != "" && d.document.Has("environments", d.Environment, "board") {
+		return []interface{}{"environments", d.Environment, "board"}
+	}
+	return []interface{}{"board"}
+}
+
+// ProblemAt returns a problem at the value of a path of the project file
+func (d *ProjectDetails) ProblemAt(message string, path ...interface{}) Problem {
+	return d.document.ProblemAt(message, path...)
+}
+
+// Validate checks the document against the schema of the project file and checks the mutually exclusive fields,
+// the versions and the zip files of the dependencies. All problems are returned in the order of their positions.
+func (d *Document) Validate() []Problem {
+	var schema map[string]interface{}
+	err := json.Unmarshal([]byte(Schema), &schema)
+	if err != nil {
+		panic(fmt.Sprintf("invalid schema of %s: %s", ProjectDetailsFileName, err))
+	}
+	v := &validator{document: d, root: schema}
+	v.validateSchema(schema, d.root, nil)
+
+	// semantic checks of the values which match the schema
+	v.validateBoard([]interface{}{"board"})
+	v.validateDependencies([]interface{}{"dependencies"})
+	v.validateDependencies([]interface{}{"dev_dependencies"})
+	if groups := d.lookup("groups"); groups != nil && groups.Kind == "object" {
+		for _, field := range groups.Fields {
+			v.validateDependencies([]interface{}{"groups", field.Key, "dependencies"})
+		}
+		if d.lookup("dev_dependencies") != nil && groups.field(DevGroup) != nil {
+			v.add(fmt.Sprintf("'dev_dependencies' and the '%s' group are mutually exclusive", DevGroup), []interface{}{"groups", DevGroup})
+		}
+	}
+	if environments := d.lookup("environments"); environments != nil && environments.Kind == "object" {
+		for _, field := range environments.Fields {
+			v.validateBoard([]interface{}{"environments", field.Key, "board"})
+			v.validateDependencies([]interface{}{"environments", field.Key, "dependencies"})
+		}
+	}
+	SortProblems(v.problems)
+	return v.problems
+}
+
+type validator struct {
+	document *Document
+	root     map[string]interface{}
+	problems []Problem
+}
+
+func (v *validator) add(message string, path []interface{}) {
+	v.problems = append(v.problems, v.document.ProblemAt(message, path...))
+}
+
+func appendPath(path []interface{}, element interface{}) []interface{} {
+	result := make([]interface{}, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, element)
+}
+
+// validateSchema checks a node against the supported subset of JSON Schema:
+// $ref, type, enum, minimum, required, properties, patternProperties, additionalProperties and items
+func (v *validator) validateSchema(schema map[string]interface{}, node *jsonNode, path []interface{}) {
+	if ref, ok := schema["$ref"].(string); ok {
+		definitions, _ := v.root["definitions"].(map[string]interface{})
+		definition, ok := definitions[strings.TrimPrefix(ref, "#/definitions/")].(map[string]interface{})
+		if !ok {
+			panic(fmt.Sprintf("unknown reference '%s' in the schema of %s", ref, ProjectDetailsFileName))
+		}
+		v.validateSchema(definition, node, path)
+		return
+	}
+
+	if types, ok := schema["type"]; ok && !matchesType(types, node) {
+		v.add(fmt.Sprintf("expected %s, got %s", formatTypes(types), node.Kind), path)
+		return
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		var values []string
+		for _, value := range enum {
+			values = append(values, fmt.Sprint(value))
+			found = found || (node.Kind != "object" && node.Kind != "array" && fmt.Sprint(value) == fmt.Sprint(node.Value))
+		}
+		if !found {
+			v.add(fmt.Sprintf("invalid value, expected one of: %s", strings.Join(values, ", ")), path)
+		}
+	}
+	if minimum, ok := schema["minimum"].(float64); ok && node.Kind == "number" {
+		if value, err := strconv.ParseFloat(fmt.Sprint(node.Value), 64); err == nil && value < minimum {
+			v.add(fmt.Sprintf("must be at least %v", minimum), path)
+		}
+	}
+
+	switch node.Kind {
+	case "object":
+		properties, _ := schema["properties"].(map[string]interface{})
+		seen := make(map[string]bool)
+		for _, field := range node.Fields {
+			fieldPath := appendPath(path, field.Key)
+			if seen[field.Key] {
+				v.add("duplicate field", fieldPath)
+			}
+			seen[field.Key] = true
+			if property, ok := properties[field.Key].(map[string]interface{}); ok {
+				v.validateSchema(property, field.Value, fieldPath)
+				continue
+			}
+			if pattern := matchingPattern(schema, field.Key); pattern != nil {
+				v.validateSchema(pattern, field.Value, fieldPath)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					message := fmt.Sprintf("unknown field '%s'", field.Key)
+					if suggestion := closestKey(field.Key, properties); suggestion != "" {
+						message = fmt.Sprintf("%s, did you mean '%s'?", message, suggestion)
+					}
+					problem := v.document.ProblemAt(message, fieldPath...)
+					// the key is reported instead of its value
+					problem.Position = v.document.linePosition(field.KeyLine, field.KeyColumn)
+					problem.line, problem.column = field.KeyLine, field.KeyColumn
+					v.problems = append(v.problems, problem)
+				}
+			case map[string]interface{}:
+				v.validateSchema(additional, field.Value, fieldPath)
+			}
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, key := range required {
+				if !seen[fmt.Sprint(key)] {
+					v.add(fmt.Sprintf("missing field '%s'", key), path)
+				}
+			}
+		}
+	case "array":
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range node.Items {
+				v.validateSchema(items, item, appendPath(path, i))
+			}
+		}
+	}
+}
+
+// matchingPattern returns the schema of the first pattern property matching the key
+func matchingPattern(schema map[string]interface{}, key string) map[string]interface{} {
+	patterns, _ := schema["patternProperties"].(map[string]interface{})
+	for pattern, patternSchema := range patterns {
+		if matched, err := regexp.MatchString(pattern, key); err == nil && matched {
+			result, _ := patternSchema.(map[string]interface{})
+			return result
+		}
+	}
+	return nil
+}
+
+func matchesType(types interface{}, node *jsonNode) bool {
+	switch value := types.(type) {
+	case string:
+		if value == "integer" {
+			return node.Kind == "number" && !strings.ContainsAny(fmt.Sprint(node.Value), ".eE")
+		}
+		return value == node.Kind
+	case []interface{}:
+		for _, t := range value {
+			if matchesType(t, node) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatTypes(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		var names []string
+		for _, t := range list {
+			names = append(names, fmt.Sprint(t))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+// closestKey returns the known key which is the most similar to a mistyped key
+func closestKey(key string, properties map[string]interface{}) string {
+	result := ""
+	best := 3
+	for property := range properties {
+		if distance := editDistance(strings.ToLower(key), property); distance < best || (distance == best && property < result) {
+			result = property
+			best = distance
+		}
+	}
+	return result
+}
+
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// validateBoard checks the fields of a board which depend on each other
+func (v *validator) validateBoard(path []interface{}) {
+	board := v.document.lookup(path...)
+	if board == nil || board.Kind != "object" {
+		return
+	}
+	pkg := board.stringField("package")
+	arch := board.stringField("architecture")
+	if (pkg == "") != (arch == "") {
+		v.add("'package' and 'architecture' must be set together", path)
+	}
+	if pkg != "" && board.field("version") == nil {
+		v.add(fmt.Sprintf("missing version of board core '%s:%s'", pkg, arch), path)
+	}
+	if version := board.stringField("version"); version != "" {
+		if _, err := ParseVersionSpec(version); err != nil {
+			v.add(err.Error(), appendPath(path, "version"))
+		}
+	}
+	if board.stringField("board") != "" && board.stringField("fqbn") != "" {
+		v.add("'board' and 'fqbn' are mutually exclusive", appendPath(path, "fqbn"))
+	}
+	if board.stringField("board") != "" && pkg == "" {
+		v.add("'board' needs 'package' and 'architecture'", appendPath(path, "board"))
+	}
+	if options := board.field("options"); options != nil && board.stringField("board") == "" && board.stringField("fqbn") == "" {
+		v.add("'options' need 'board' or 'fqbn'", appendPath(path, "options"))
+	}
+
+	// additional platforms, every platform can only be installed once
+	platforms := board.field("platforms")
+	if platforms == nil || platforms.Kind != "array" {
+		return
+	}
+	seen := map[string]bool{fmt.Sprintf("%s:%s", pkg, arch): pkg != ""}
+	for i, platform := range platforms.Items {
+		if platform.Kind != "object" {
+			continue
+		}
+		platformPath := appendPath(appendPath(path, "platforms"), i)
+		id := fmt.Sprintf("%s:%s", platform.stringField("package"), platform.stringField("architecture"))
+		if seen[id] {
+			v.add(fmt.Sprintf("platform '%s' is already required by the board", id), platformPath)
+		}
+		seen[id] = true
+		if version := platform.stringField("version"); version != "" {
+			if _, err := ParseVersionSpec(version); err != nil {
+				v.add(err.Error(), appendPath(platformPath, "version"))
+			}
+		}
+	}
+}
+
+// validateDependencies checks the sources, versions and zip files of the dependencies
+func (v *validator) validateDependencies(path []interface{}) {
+	deps := v.document.lookup(path...)
+	if deps == nil || deps.Kind != "array" {
+		return
+	}
+	for i, dep := range deps.Items {
+		if dep.Kind != "object" {
+			continue
+		}
+		depPath := appendPath(path, i)
+		var sources []string
+		for _, source := range []string{"library", "git", "zip"} {
+			if dep.field(source) != nil {
+				sources = append(sources, source)
+			}
+		}
+		if len(sources) == 0 {
+			v.add("one of 'library', 'git' or 'zip' must be set", depPath)
+		} else if len(sources) > 1 {
+			v.add(fmt.Sprintf("'%s' are mutually exclusive", strings.Join(sources, "' and '")), appendPath(depPath, sources[1]))
+		}
+
+		library := dep.stringField("library")
+		if dep.field("library") != nil && dep.field("version") == nil {
+			v.add(fmt.Sprintf("missing version of library '%s'", library), depPath)
+		}
+		if dep.field("version") != nil && dep.field("library") == nil {
+			v.add("'version' can only be used with 'library'", appendPath(depPath, "version"))
+		}
+		if version := dep.stringField("version"); version != "" {
+			if _, err := ParseVersionSpec(version); err != nil {
+				v.add(err.Error(), appendPath(depPath, "version"))
+			}
+		}
+		if dep.field("ref") != nil && dep.field("git") == nil {
+			v.add("'ref' can only be used with 'git'", appendPath(depPath, "ref"))
+		}
+		if dep.field("sha256") != nil && dep.field("library") != nil {
+			v.add("'sha256' can only be used with 'git' or 'zip'", appendPath(depPath, "sha256"))
+		}
+		if zip := dep.stringField("zip"); zip != "" && !util.FileExists(zip) {
+			v.add(fmt.Sprintf("zip file '%s' not found", zip), appendPath(depPath, "zip"))
+		}
+	}
+}
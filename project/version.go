@@ -0,0 +1,400 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	semver "go.bug.st/relaxed-semver"
+)
+
+// VersionSpec is the version of a dependency in apm.json.
+// Supported forms: latest, 1.2.3, ^2.3.0, ~1.2, >=3.0.0 <4, 2.x, 1.0.0 - 1.4.0 and alternatives separated by ||
+type VersionSpec struct {
+	raw          string
+	alternatives [][]versionComparator
+}
+
+type versionComparator struct {
+	op      string
+	version *semver.Version
+	// original text of the version, versions that are not semantic versions can only match exactly
+	raw string
+}
+
+var partialVersionRegex = regexp.MustCompile(`^v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?((?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?)$`)
+var operatorSpaceRegex = regexp.MustCompile(`(>=|<=|>|<|=|\^|~)\s+`)
+var hyphenRangeRegex = regexp.MustCompile(`^(\S+)\s+-\s+(\S+)$`)
+
+// IsLatest returns true if the version means "always the newest release"
+func IsLatest(version string) bool {
+	return strings.ToLower(version) == "latest"
+}
+
+func ParseVersionSpec(spec string) (*VersionSpec, error) {
+	result := &VersionSpec{raw: spec}
+	spec = strings.TrimSpace(spec)
+	if IsLatest(spec) {
+		spec = "*"
+	}
+	for _, alternative := range strings.Split(spec, "||") {
+		alternative = operatorSpaceRegex.ReplaceAllString(strings.TrimSpace(alternative), "$1")
+		var comparators []versionComparator
+		var err error
+		if match := hyphenRangeRegex.FindStringSubmatch(alternative); match != nil {
+			comparators, err = parseHyphenRange(match[1], match[2])
+		} else {
+			for _, part := range strings.Fields(alternative) {
+				var partComparators []versionComparator
+				partComparators, err = parseComparator(part)
+				if err != nil {
+					break
+				}
+				comparators = append(comparators, partComparators...)
+			}
+		}
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid version '%s': %s", result.raw, err))
+		}
+		result.alternatives = append(result.alternatives, comparators)
+	}
+	return result, nil
+}
+
+func (s *VersionSpec) String() string {
+	return s.raw
+}
+
+// Match returns true if the concrete version satisfies the spec
+func (s *VersionSpec) Match(version string) bool {
+	parsed, err := parseVersion(version)
+	for _, alternative := range s.alternatives {
+		if matchAlternative(alternative, version, parsed, err == nil) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAlternative(comparators []versionComparator, version string, parsed *semver.Version, isSemver bool) bool {
+	// pre-releases are matched only if a comparator has a pre-release of the same major.minor.patch version
+	// (e.g. ^1.2.3-beta.1 matches 1.2.3-beta.2, but not 1.2.4-beta)
+	allowPrerelease := false
+	for _, c := range comparators {
+		if c.op == "=" && c.raw == version {
+			allowPrerelease = true
+			continue
+		}
+		if c.version != nil && isPrerelease(c.version.String()) && releaseOf(c.version.String()) == releaseOf(version) {
+			allowPrerelease = true
+		}
+		if c.version == nil || !isSemver {
+			return false
+		}
+		matches := false
+		switch c.op {
+		case "=":
+			matches = parsed.Equal(c.version)
+		case ">":
+			matches = parsed.GreaterThan(c.version)
+		case ">=":
+			matches = parsed.GreaterThanOrEqual(c.version)
+		case "<":
+			matches = parsed.LessThan(c.version)
+		case "<=":
+			matches = parsed.LessThanOrEqual(c.version)
+		}
+		if !matches {
+			return false
+		}
+	}
+	return allowPrerelease || !isPrerelease(version)
+}
+
+func parseComparator(part string) ([]versionComparator, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(part, candidate) {
+			op = candidate
+			break
+		}
+	}
+	versionPart := strings.TrimPrefix(part, op)
+
+	match := partialVersionRegex.FindStringSubmatch(versionPart)
+	if match == nil {
+		// not a semantic version, only usable as an exact version
+		if op != "" && op != "=" {
+			return nil, errors.New(fmt.Sprintf("'%s' is not a valid version", versionPart))
+		}
+		return []versionComparator{{op: "=", raw: versionPart}}, nil
+	}
+
+	// numbers defined before the first wildcard
+	var numbers []int
+	wildcard := false
+	for _, n := range match[1:4] {
+		if n == "x" || n == "X" || n == "*" {
+			wildcard = true
+		}
+		if n == "" || wildcard {
+			break
+		}
+		number, _ := strconv.Atoi(n)
+		numbers = append(numbers, number)
+	}
+	suffix := match[4]
+	if len(numbers) < 3 {
+		suffix = ""
+	}
+	lower := boundVersion(numbers, suffix)
+
+	// versions without any wildcard are exact versions (e.g. 1.0 means 1.0, not 1.0.x)
+	if (op == "" || op == "=") && !wildcard {
+		parsed, err := parseVersion(versionPart)
+		if err != nil {
+			return nil, err
+		}
+		return []versionComparator{{op: "=", version: parsed, raw: versionPart}}, nil
+	}
+
+	// any version
+	if len(numbers) == 0 && op != ">" && op != "<" {
+		return nil, nil
+	}
+
+	switch op {
+	case "", "=":
+		return rangeComparators(lower, upperBound(numbers, len(numbers)-1), versionPart), nil
+	case ">":
+		if len(numbers) == 0 {
+			return comparator("<", "0.0.0", versionPart), nil
+		}
+		if len(numbers) == 3 {
+			return comparator(">", lower, versionPart), nil
+		}
+		return comparator(">=", upperBound(numbers, len(numbers)-1), versionPart), nil
+	case ">=":
+		return comparator(">=", lower, versionPart), nil
+	case "<":
+		if len(numbers) == 0 {
+			return comparator("<", "0.0.0", versionPart), nil
+		}
+		return comparator("<", lower, versionPart), nil
+	case "<=":
+		if len(numbers) == 3 {
+			return comparator("<=", lower, versionPart), nil
+		}
+		return comparator("<", upperBound(numbers, len(numbers)-1), versionPart), nil
+	case "~":
+		if len(numbers) == 3 {
+			return rangeComparators(lower, upperBound(numbers, 1), versionPart), nil
+		}
+		return rangeComparators(lower, upperBound(numbers, len(numbers)-1), versionPart), nil
+	case "^":
+		// the first non-zero number must not change
+		bump := 0
+		for bump < len(numbers)-1 && numbers[bump] == 0 {
+			bump++
+		}
+		return rangeComparators(lower, upperBound(numbers, bump), versionPart), nil
+	}
+	return nil, errors.New(fmt.Sprintf("unknown operator in '%s'", part))
+}
+
+func parseHyphenRange(from string, to string) ([]versionComparator, error) {
+	lower, err := parseComparator(">=" + from)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := parseComparator("<=" + to)
+	if err != nil {
+		return nil, err
+	}
+	return append(lower, upper...), nil
+}
+
+// boundVersion builds a full version from the defined numbers (missing ones are 0)
+func boundVersion(numbers []int, suffix string) string {
+	full := []int{0, 0, 0}
+	copy(full, numbers)
+	return fmt.Sprintf("%d.%d.%d%s", full[0], full[1], full[2], suffix)
+}
+
+// upperBound returns the version where the number at the given index is increased (e.g. 1.2.3 -> 1.3.0 for index 1)
+func upperBound(numbers []int, index int) string {
+	bumped := make([]int, index+1)
+	copy(bumped, numbers[:index+1])
+	bumped[index]++
+	return boundVersion(bumped, "")
+}
+
+func comparator(op string, version string, raw string) []versionComparator {
+	return []versionComparator{{op: op, version: semver.MustParse(version), raw: raw}}
+}
+
+func rangeComparators(lower string, upper string, raw string) []versionComparator {
+	return append(comparator(">=", lower, raw), comparator("<", upper, raw)...)
+}
+
+func parseVersion(version string) (*semver.Version, error) {
+	return semver.Parse(strings.TrimPrefix(version, "v"))
+}
+
+func isPrerelease(version string) bool {
+	return strings.Contains(strings.SplitN(version, "+", 2)[0], "-")
+}
+
+// releaseOf returns the major.minor.patch version of a version without its pre-release and build (e.g. 1.2.0 for 1.2-beta)
+func releaseOf(version string) string {
+	release := strings.SplitN(strings.SplitN(version, "+", 2)[0], "-", 2)[0]
+	parsed, err := parseVersion(release)
+	if err != nil {
+		return release
+	}
+	parsed.Normalize()
+	return parsed.String()
+}
+
+// VersionSatisfies checks if a concrete (e.g. locked or installed) version fulfills the version set in apm.json
+func VersionSatisfies(spec string, version string) bool {
+	if version == "" {
+		return false
+	}
+	parsedSpec, err := ParseVersionSpec(spec)
+	if err != nil {
+		return spec == version
+	}
+	return parsedSpec.Match(version)
+}
+
+// SatisfiesAmong checks if a version of a library (or board core package) with the given releases fulfills the version
+// set in apm.json, "latest" is the newest pre-release if there are only pre-releases
+func SatisfiesAmong(spec string, version string, versions []string) bool {
+	if VersionSatisfies(spec, version) {
+		return true
+	}
+	if !IsLatest(strings.TrimSpace(spec)) || version == "" {
+		return false
+	}
+	for _, v := range versions {
+		if !isPrerelease(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// LockedVersionSatisfies checks if a locked version still fulfills the version set in apm.json, a locked pre-release
+// fulfills "latest" as it was only locked because there was no stable release
+func LockedVersionSatisfies(spec string, version string) bool {
+	return VersionSatisfies(spec, version) || (version != "" && IsLatest(strings.TrimSpace(spec)))
+}
+
+// MaxSatisfying returns the newest version that fulfills the spec or an empty string if there is none
+func MaxSatisfying(spec string, versions []string) string {
+	result := ""
+	for _, version := range versions {
+		if SatisfiesAmong(spec, version, versions) && (result == "" || CompareVersions(version, result) > 0) {
+			result = version
+		}
+	}
+	return result
+}
+
+// CompareVersions compares two concrete versions, versions that are not semantic versions are compared as strings
+func CompareVersions(a string, b string) int {
+	parsedA, errA := parseVersion(a)
+	parsedB, errB := parseVersion(b)
+	if errA == nil && errB == nil {
+		return parsedA.CompareTo(parsedB)
+	}
+	return strings.Compare(a, b)
+}
+
+// SortVersions sorts versions from the oldest to the newest
+func SortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) < 0
+	})
+}
+
+// ArduinoConstraintSatisfies checks a version constraint of the Arduino library index (e.g. ">=1.2.0", "=1.0.0")
+func ArduinoConstraintSatisfies(constraint string, version string) bool {
+	if strings.TrimSpace(constraint) == "" {
+		return true
+	}
+	parsedConstraint, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return VersionSatisfies(constraint, version)
+	}
+	parsed, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+	return parsedConstraint.Match(parsed)
+}
+
+// BumpVersionSpec returns the version to store in apm.json when a dependency is updated to the given version.
+// "latest" and ranges which still allow the version are kept, other ranges keep their operator style: ^ and ~ ranges
+// keep their operator, x-ranges their wildcards (2.x becomes 3.x) and any other range becomes a ^ range.
+// Exact versions are replaced by the version.
+func BumpVersionSpec(spec string, version string) string {
+	spec = strings.TrimSpace(spec)
+	if IsLatest(spec) {
+		return spec
+	}
+	if (strings.HasPrefix(spec, "^") || strings.HasPrefix(spec, "~")) && !strings.ContainsAny(spec, " |") {
+		return spec[:1] + version
+	}
+	parsedSpec, err := ParseVersionSpec(spec)
+	if err != nil || parsedSpec.isExact() {
+		return version
+	}
+	if parsedSpec.Match(version) {
+		return spec
+	}
+	if wildcard := bumpWildcards(spec, version); wildcard != "" {
+		return wildcard
+	}
+	return "^" + version
+}
+
+// bumpWildcards returns an x-range (e.g. 2.x or 1.2.*) with the numbers before its wildcards taken from the version
+// or an empty string if the spec is not an x-range
+func bumpWildcards(spec string, version string) string {
+	match := partialVersionRegex.FindStringSubmatch(strings.TrimPrefix(spec, "="))
+	if match == nil || match[4] != "" {
+		return ""
+	}
+	numbers := strings.Split(releaseOf(version), ".")
+	var parts []string
+	wildcard := false
+	for i, part := range match[1:4] {
+		if part == "" {
+			break
+		}
+		if part == "x" || part == "X" || part == "*" {
+			wildcard = true
+		}
+		if !wildcard && i < len(numbers) {
+			part = numbers[i]
+		}
+		parts = append(parts, part)
+	}
+	if !wildcard {
+		return ""
+	}
+	prefix := ""
+	if strings.HasPrefix(strings.TrimPrefix(spec, "="), "v") {
+		prefix = "v"
+	}
+	return prefix + strings.Join(parts, ".")
+}
+
+func (s *VersionSpec) isExact() bool {
+	return len(s.alternatives) == 1 && len(s.alternatives[0]) == 1 && s.alternatives[0][0].op == "="
+}
@@ -0,0 +1,133 @@
+package project
+
+import "testing"
+
+func TestParseVersionSpec(t *testing.T) {
+	valid := []string{"latest", "LATEST", "*", "1.2.3", "v1.2.3", "1.0", "^2.3.0", "~1.2", ">= 3.0.0 < 4", "2.x", "1.2.*",
+		"1.0.0 - 1.4", "^1.0.0 || ^3.0.0", "1.2.3-beta.1", "nightly"}
+	for _, spec := range valid {
+		if _, err := ParseVersionSpec(spec); err != nil {
+			t.Errorf("ParseVersionSpec(%q) failed: %s", spec, err)
+		}
+	}
+	invalid := []string{"^nope", ">=abc", "~", "1.0.0 - nope"}
+	for _, spec := range invalid {
+		if _, err := ParseVersionSpec(spec); err == nil {
+			t.Errorf("ParseVersionSpec(%q) should fail", spec)
+		}
+	}
+}
+
+func TestVersionSatisfies(t *testing.T) {
+	tests := []struct {
+		spec     string
+		version  string
+		expected bool
+	}{
+		{"latest", "1.2.3", true},
+		{"latest", "1.2.3-beta", false},
+		{"*", "0.0.1", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.0", "1.0", true},
+		{"1.0", "1.0.1", false},
+		{"nightly", "nightly", true},
+		{"nightly", "1.0.0", false},
+		{"^2.3.0", "2.3.0", true},
+		{"^2.3.0", "2.9.9", true},
+		{"^2.3.0", "3.0.0", false},
+		{"^2.3.0", "2.2.9", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"~1.2", "1.2.9", true},
+		{"~1.2", "1.3.0", false},
+		{"~1.2.3", "1.2.2", false},
+		{"~1", "1.9.0", true},
+		{"2.x", "2.5.1", true},
+		{"2.x", "3.0.0", false},
+		{"1.2.*", "1.2.7", true},
+		{"1.2.*", "1.3.0", false},
+		{">=3.0.0 <4", "3.5.0", true},
+		{">=3.0.0 <4", "4.0.0", false},
+		{">= 3.0.0", "3.0.0", true},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{"1.0.0 - 1.4", "1.4.9", true},
+		{"1.0.0 - 1.4", "1.5.0", false},
+		{"1.0.0 - 1.4", "0.9.9", false},
+		{"^1.0.0 || ^3.0.0", "1.5.0", true},
+		{"^1.0.0 || ^3.0.0", "2.0.0", false},
+		{"^1.0.0 || ^3.0.0", "3.1.0", true},
+		{"1.2.3-beta.1", "1.2.3-beta.1", true},
+		{"^1.2.3-beta.1", "1.2.3-beta.2", true},
+		{"^1.2.3-beta.1", "1.2.3", true},
+		{"^1.2.3-beta.1", "1.2.4-beta", false},
+		{"^1.2.3-beta.1", "1.2.4", true},
+		{">=1.0.0-rc.1 <2", "1.0.0-rc.2", true},
+		{">=1.0.0-rc.1 <2", "1.5.0-rc.1", false},
+		{"^1.0.0", "1.1.0-beta", false},
+		{"^1.0.0", "", false},
+	}
+	for _, test := range tests {
+		if actual := VersionSatisfies(test.spec, test.version); actual != test.expected {
+			t.Errorf("VersionSatisfies(%q, %q) = %v, expected %v", test.spec, test.version, actual, test.expected)
+		}
+	}
+}
+
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.10.0", "2.0.0-beta", "2.0.0", "2.1.0", "3.0.0-rc.1"}
+	tests := []struct {
+		spec     string
+		versions []string
+		expected string
+	}{
+		{"latest", versions, "2.1.0"},
+		{"^1.0.0", versions, "1.10.0"},
+		{"~1.2", versions, "1.2.0"},
+		{"2.x", versions, "2.1.0"},
+		{"<2", versions, "1.10.0"},
+		{"^3.0.0-rc.1", versions, "3.0.0-rc.1"},
+		{"^4.0.0", versions, ""},
+		{"latest", []string{"0.1.0-beta", "0.2.0-beta", "0.1.1-beta"}, "0.2.0-beta"},
+		{"^0.1.0", []string{"0.1.0-beta", "0.2.0-beta"}, ""},
+		{"latest", nil, ""},
+	}
+	for _, test := range tests {
+		if actual := MaxSatisfying(test.spec, test.versions); actual != test.expected {
+			t.Errorf("MaxSatisfying(%q, %v) = %q, expected %q", test.spec, test.versions, actual, test.expected)
+		}
+	}
+}
+
+func TestBumpVersionSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		version  string
+		expected string
+	}{
+		{"latest", "3.0.0", "latest"},
+		{"1.2.3", "1.3.0", "1.3.0"},
+		{"^2.3.0", "2.4.0", "^2.4.0"},
+		{"^2.3.0", "3.0.0", "^3.0.0"},
+		{"~1.2", "1.2.5", "~1.2.5"},
+		{"2.x", "2.5.0", "2.x"},
+		{"2.x", "3.0.0", "3.x"},
+		{"2.*", "3.1.0", "3.*"},
+		{"1.2.x", "2.0.1", "2.0.x"},
+		{"v1.x", "2.0.0", "v2.x"},
+		{">=1.0.0", "3.0.0", ">=1.0.0"},
+		{">=1.0.0 <2", "1.5.0", ">=1.0.0 <2"},
+		{">=1.0.0 <2", "3.0.0", "^3.0.0"},
+		{"1.0.0 - 1.4", "2.0.0", "^2.0.0"},
+		{"^1.0.0 || ^2.0.0", "3.0.0", "^3.0.0"},
+	}
+	for _, test := range tests {
+		if actual := BumpVersionSpec(test.spec, test.version); actual != test.expected {
+			t.Errorf("BumpVersionSpec(%q, %q) = %q, expected %q", test.spec, test.version, actual, test.expected)
+		}
+	}
+}
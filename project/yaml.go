@@ -0,0 +1,442 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlStyles are the styles of YAML values which are kept when the project file is rewritten
+var yamlStyles = map[yaml.Style]string{
+	yaml.DoubleQuotedStyle: styleDoubleQuoted,
+	yaml.SingleQuotedStyle: styleSingleQuoted,
+	yaml.LiteralStyle:      styleLiteral,
+	yaml.FoldedStyle:       styleFolded,
+	yaml.FlowStyle:         styleFlow,
+}
+
+func parseYamlDocument(file string, data []byte) (*Document, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", file, strings.TrimPrefix(err.Error(), "yaml: ")))
+	}
+	result := &Document{File: file, Format: FormatYaml, data: data}
+	if len(document.Content) == 0 {
+		// empty file
+		result.root = &jsonNode{Kind: "object", Line: 1, Column: 1}
+		return result, nil
+	}
+	result.root, err = yamlToNode(document.Content[0])
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s: %s", file, err))
+	}
+	return result, nil
+}
+
+// yamlToNode converts a YAML node to the node of a project file
+func yamlToNode(node *yaml.Node) (*jsonNode, error) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		return yamlToNode(node.Alias)
+	}
+	result := &jsonNode{Line: node.Line, Column: node.Column, Style: yamlStyles[node.Style&^yaml.TaggedStyle]}
+	switch node.Kind {
+	case yaml.MappingNode:
+		result.Kind = "object"
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			value, err := yamlToNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			result.Fields = append(result.Fields, &jsonField{Key: key.Value, KeyLine: key.Line, KeyColumn: key.Column, Value: value})
+		}
+	case yaml.SequenceNode:
+		result.Kind = "array"
+		for _, item := range node.Content {
+			value, err := yamlToNode(item)
+			if err != nil {
+				return nil, err
+			}
+			result.Items = append(result.Items, value)
+		}
+	default:
+		switch node.ShortTag() {
+		case "!!int", "!!float":
+			var value interface{}
+			err := node.Decode(&value)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("line %d: %s", node.Line, err))
+			}
+			result.Kind = "number"
+			result.Value = json.Number(fmt.Sprint(value))
+		case "!!bool":
+			var value bool
+			err := node.Decode(&value)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("line %d: %s", node.Line, err))
+			}
+			result.Kind = "boolean"
+			result.Value = value
+		case "!!null":
+			result.Kind = "null"
+		default:
+			result.Kind = "string"
+			result.Value = node.Value
+		}
+	}
+	return result, nil
+}
+
+// yamlComments returns the comments of a YAML project file
+func yamlComments(data []byte) (comments, error) {
+	var document yaml.Node
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+	result := comments{}
+	result.add(nil, document.HeadComment, document.LineComment, document.FootComment)
+	if len(document.Content) > 0 {
+		err = collectYamlComments(document.Content[0], nil, result)
+	}
+	return result, err
+}
+
+func collectYamlComments(node *yaml.Node, path []string, result comments) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			value := node.Content[i+1]
+			valuePath := appendString(path, key.Value)
+			if value.Kind == yaml.ScalarNode {
+				result.add(valuePath, joinComments(key.HeadComment, value.HeadComment), joinComments(key.LineComment, value.LineComment), joinComments(key.FootComment, value.FootComment))
+				continue
+			}
+			result.add(valuePath, key.HeadComment, key.LineComment, key.FootComment)
+			err := collectYamlComments(value, valuePath, result)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			converted, err := yamlToNode(item)
+			if err != nil {
+				return err
+			}
+			itemPath := appendString(path, itemIdentity(converted, i))
+			// the comment above "- library: ..." belongs to the item, not to its first key
+			head := item.HeadComment
+			if item.Kind == yaml.MappingNode && len(item.Content) > 0 {
+				head = joinComments(head, item.Content[0].HeadComment)
+				item.Content[0].HeadComment = ""
+			}
+			result.add(itemPath, head, item.LineComment, item.FootComment)
+			if item.Kind != yaml.ScalarNode {
+				err = collectYamlComments(item, itemPath, result)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	result.add(path, node.HeadComment, node.LineComment, node.FootComment)
+	return nil
+}
+
+// yamlWriter writes a YAML project file: the values keep their style (e.g. quotes) from the previous version of the
+// project file, which also gives the indentation, the empty lines and the comments
+type yamlWriter struct {
+	buffer   bytes.Buffer
+	comments comments
+	merger   *merger
+	// lines of the previous YAML project file
+	lines []string
+	// indentation of the keys of objects in objects
+	indent int
+	// indentation of the "-" of the items of arrays in objects and of the values after it
+	itemIndent      int
+	itemValueIndent int
+}
+
+// marshalYaml returns the YAML project file of a merged project file with the given comments,
+// previous is the previous YAML project file or nil
+func marshalYaml(root *jsonNode, projectComments comments, m *merger, previous *Document) []byte {
+	w := &yamlWriter{comments: projectComments, merger: m, indent: 2, itemValueIndent: 2}
+	if previous != nil {
+		w.lines = strings.Split(string(previous.data), "\n")
+		w.yamlIndentation(previous.root)
+	}
+	documentComment := projectComments.get(nil)
+	if documentComment.Head != "" {
+		w.buffer.WriteString(documentComment.Head + "\n\n")
+	}
+	if len(root.Fields) == 0 {
+		w.buffer.WriteString("{}\n")
+	} else {
+		w.writeObject(root, m.styleOf(root, nil), nil, 0, false, "")
+	}
+	if documentComment.Foot != "" {
+		w.buffer.WriteString("\n" + documentComment.Foot + "\n")
+	}
+	return w.buffer.Bytes()
+}
+
+// yamlIndentation detects the indentation of the objects and arrays of a YAML project file from the first ones
+// written in block style
+func (w *yamlWriter) yamlIndentation(root *jsonNode) {
+	indent, foundItems := 0, false
+	var walk func(node *jsonNode)
+	walk = func(node *jsonNode) {
+		for _, field := range node.Fields {
+			value := field.Value
+			if value.Style == styleFlow {
+				continue
+			}
+			if value.Kind == "object" && len(value.Fields) > 0 {
+				if indent == 0 && value.Fields[0].KeyColumn > field.KeyColumn {
+					indent = value.Fields[0].KeyColumn - field.KeyColumn
+				}
+				walk(value)
+			} else if value.Kind == "array" && len(value.Items) > 0 {
+				if !foundItems && value.Column >= field.KeyColumn {
+					// the column of an array is the column of its first "-"
+					foundItems = true
+					w.itemIndent = value.Column - field.KeyColumn
+					if first := value.Items[0]; first.Line == value.Line && first.Column > value.Column {
+						w.itemValueIndent = first.Column - value.Column
+					}
+				}
+				for _, item := range value.Items {
+					if item.Kind == "object" && item.Style != styleFlow {
+						walk(item)
+					}
+				}
+			}
+		}
+	}
+	walk(root)
+	if indent > 0 {
+		w.indent = indent
+	}
+}
+
+// writeObject writes the keys of an object, if inline is set the first key continues the line
+// (e.g. after the "-" of an array item) and the comment of that line is written after its value
+func (w *yamlWriter) writeObject(node *jsonNode, source *jsonNode, path []string, indent int, inline bool, lineComment string) {
+	for i, field := range node.Fields {
+		valuePath := appendString(path, field.Key)
+		c := w.comments.get(valuePath)
+		var previous *jsonField
+		if source != nil {
+			for _, f := range source.Fields {
+				if f.Key == field.Key {
+					previous = f
+				}
+			}
+		}
+		comment := c.Line
+		if i == 0 && inline {
+			comment = joinComments(lineComment, comment)
+		} else {
+			if previous != nil {
+				w.writeBlankLine(previous.KeyLine, c.Head)
+			}
+			w.writeComment(c.Head, indent)
+			w.buffer.WriteString(strings.Repeat(" ", indent))
+		}
+		w.buffer.WriteString(w.scalar(&jsonNode{Kind: "string", Value: field.Key}, nil, false, indent) + ":")
+		var fallback *jsonNode
+		if previous != nil {
+			fallback = previous.Value
+		}
+		w.writeValue(field.Value, w.merger.styleOf(field.Value, fallback), valuePath, indent, comment)
+		w.writeComment(c.Foot, indent)
+	}
+}
+
+// writeValue writes the value of a key after the colon
+func (w *yamlWriter) writeValue(node *jsonNode, source *jsonNode, path []string, indent int, lineComment string) {
+	if !isYamlBlock(node, source) {
+		w.buffer.WriteString(" " + w.flowValue(node, source, false, indent))
+		w.writeLineComment(lineComment)
+		return
+	}
+	w.writeLineComment(lineComment)
+	if node.Kind == "object" {
+		w.writeObject(node, source, path, indent+w.indent, false, "")
+		return
+	}
+	w.writeArray(node, source, path, indent+w.itemIndent)
+}
+
+// writeArray writes the items of an array in block style
+func (w *yamlWriter) writeArray(node *jsonNode, source *jsonNode, path []string, indent int) {
+	for i, item := range node.Items {
+		itemPath := appendString(path, itemIdentity(item, i))
+		c := w.comments.get(itemPath)
+		itemSource := w.merger.styleOf(item, nil)
+		// new items have the style of the first item, but not its position
+		if itemSource != nil && i > 0 {
+			w.writeBlankLine(itemSource.Line, c.Head)
+		}
+		w.writeComment(c.Head, indent)
+		w.buffer.WriteString(strings.Repeat(" ", indent) + "-")
+		switch {
+		case !isYamlBlock(item, itemSource):
+			w.buffer.WriteString(" " + w.flowValue(item, itemSource, false, indent+w.itemValueIndent))
+			w.writeLineComment(c.Line)
+		case item.Kind == "object":
+			w.buffer.WriteString(" ")
+			w.writeObject(item, itemSource, itemPath, indent+w.itemValueIndent, true, c.Line)
+		default:
+			w.writeLineComment(c.Line)
+			w.writeArray(item, itemSource, itemPath, indent+w.itemValueIndent)
+		}
+		w.writeComment(c.Foot, indent)
+	}
+}
+
+// isYamlBlock returns true if a value is written in block style (in more lines)
+func isYamlBlock(node *jsonNode, source *jsonNode) bool {
+	return (len(node.Fields) > 0 || len(node.Items) > 0) && (source == nil || source.Style != styleFlow)
+}
+
+// flowValue returns a scalar or an object or array in flow style, the lines of multi-line strings after the first one
+// are indented under the key
+func (w *yamlWriter) flowValue(node *jsonNode, source *jsonNode, inFlow bool, indent int) string {
+	switch node.Kind {
+	case "object":
+		var fields []string
+		for _, field := range node.Fields {
+			value := w.flowValue(field.Value, w.merger.styleOf(field.Value, source.field(field.Key)), true, indent)
+			fields = append(fields, w.scalar(&jsonNode{Kind: "string", Value: field.Key}, nil, true, indent)+": "+value)
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case "array":
+		var items []string
+		for _, item := range node.Items {
+			items = append(items, w.flowValue(item, w.merger.styleOf(item, nil), true, indent))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return w.scalar(node, source, inFlow, indent)
+}
+
+// scalar returns a scalar value, strings keep the quotes of their previous version and are quoted by the rules
+// of yaml.v3 otherwise, so e.g. the version "1.0" is not written as a number
+func (w *yamlWriter) scalar(node *jsonNode, source *jsonNode, inFlow bool, indent int) string {
+	switch node.Kind {
+	case "string":
+	case "null":
+		return "null"
+	default:
+		return fmt.Sprint(node.Value)
+	}
+	value := node.Value.(string)
+	style := ""
+	if source != nil {
+		style = source.Style
+	}
+	multiline := strings.Contains(value, "\n")
+	switch {
+	case style == styleDoubleQuoted || (multiline && (inFlow || style == styleSingleQuoted)):
+		return yamlDoubleQuoted(value)
+	case style == styleSingleQuoted:
+		return yamlSingleQuoted(value)
+	case multiline:
+		yamlStyle := yaml.LiteralStyle
+		if style == styleFolded {
+			yamlStyle = yaml.FoldedStyle
+		}
+		lines := strings.Split(w.encodeScalar(value, yamlStyle), "\n")
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = strings.Repeat(" ", indent) + lines[i]
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	// long plain strings are not folded like by yaml.v3
+	switch encoded := w.encodeScalar(value, 0); {
+	case strings.HasPrefix(encoded, "'"):
+		return yamlSingleQuoted(value)
+	case strings.HasPrefix(encoded, "\"") || strings.HasPrefix(encoded, "|") || strings.HasPrefix(encoded, ">"):
+		return yamlDoubleQuoted(value)
+	case inFlow && strings.ContainsAny(value, ",[]{}"):
+		return yamlSingleQuoted(value)
+	}
+	return value
+}
+
+// encodeScalar returns a string encoded by yaml.v3 in the given style
+func (w *yamlWriter) encodeScalar(value string, style yaml.Style) string {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(w.indent)
+	err := encoder.Encode(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: style})
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		return yamlDoubleQuoted(value)
+	}
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// yamlDoubleQuoted returns a double quoted string, the escapes of JSON strings are valid in YAML
+func yamlDoubleQuoted(value string) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+func yamlSingleQuoted(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+func (w *yamlWriter) writeComment(comment string, indent int) {
+	if comment == "" {
+		return
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		if line != "" {
+			w.buffer.WriteString(strings.Repeat(" ", indent) + line)
+		}
+		w.buffer.WriteString("\n")
+	}
+}
+
+func (w *yamlWriter) writeLineComment(comment string) {
+	if comment != "" {
+		w.buffer.WriteString(" " + strings.Replace(comment, "\n", " ", -1))
+	}
+	w.buffer.WriteString("\n")
+}
+
+// writeBlankLine keeps the empty line before a value (and its comment) of the previous project file
+func (w *yamlWriter) writeBlankLine(line int, head string) {
+	if head != "" {
+		line -= strings.Count(head, "\n") + 1
+	}
+	if line < 2 || line-2 >= len(w.lines) || strings.TrimSpace(w.lines[line-2]) != "" {
+		return
+	}
+	if w.buffer.Len() > 0 && !bytes.HasSuffix(w.buffer.Bytes(), []byte("\n\n")) {
+		w.buffer.WriteString("\n")
+	}
+}
+
+func appendString(path []string, element string) []string {
+	result := make([]string, len(path), len(path)+1)
+	copy(result, path)
+	return append(result, element)
+}
@@ -0,0 +1,339 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ksrichard/apm/project"
+)
+
+// maximum number of tried library versions before giving up
+const maxSteps = 100000
+
+// Release is a version of a library in the library index
+type Release struct {
+	Name         string
+	Version      string
+	Dependencies []Dependency
+}
+
+// Dependency is a library needed by a release, Constraint uses the syntax of the Arduino library index (e.g. ">=1.2.0")
+type Dependency struct {
+	Name       string
+	Constraint string
+}
+
+// Requirement is a library of the project, Version uses the syntax of apm.json (e.g. "latest", "^1.2.0")
+type Requirement struct {
+	Library string
+	Version string
+}
+
+// Resolved is the version chosen for a library
+type Resolved struct {
+	Name         string
+	Version      string
+	Dependencies []string
+}
+
+// Index returns all releases of a library or nil if the library does not exist
+type Index func(name string) ([]*Release, error)
+
+// Resolver chooses one version of every library needed by the project (including indirect dependencies)
+// so that every version constraint is satisfied
+type Resolver struct {
+	Index Index
+	// name of the project used in conflict explanations
+	Root string
+	// versions to try first (e.g. locked versions) by library name
+	Preferred map[string]string
+
+	releases map[string][]*Release
+	steps    int
+	conflict error
+	err      error
+}
+
+// ConflictError explains why there is no valid set of library versions
+type ConflictError struct {
+	Library     string
+	Constraints []string
+}
+
+func (e *ConflictError) Error() string {
+	if len(e.Constraints) == 1 {
+		return fmt.Sprintf("dependency conflict on '%s': no release matches %s", e.Library, e.Constraints[0])
+	}
+	return fmt.Sprintf("dependency conflict on '%s': %s", e.Library, strings.Join(e.Constraints, " conflicts with "))
+}
+
+type constraint struct {
+	name string
+	spec string
+	// spec is an Arduino library index constraint, otherwise it is an apm.json version
+	arduino bool
+	chain   []string
+}
+
+// matches returns true if a release of the library satisfies the constraint, releases are all releases of the library
+func (c constraint) matches(version string, releases []*Release) bool {
+	if c.arduino {
+		return project.ArduinoConstraintSatisfies(c.spec, version)
+	}
+	var versions []string
+	for _, release := range releases {
+		versions = append(versions, release.Version)
+	}
+	return project.SatisfiesAmong(c.spec, version, versions)
+}
+
+// String returns the full chain of the constraint, e.g. "MyProject -> A@1.2 -> B >=2.0"
+func (c constraint) String() string {
+	target := fmt.Sprintf("%s@%s", c.name, c.spec)
+	if c.arduino && strings.TrimSpace(c.spec) == "" {
+		target = c.name
+	} else if c.arduino {
+		target = fmt.Sprintf("%s %s", c.name, c.spec)
+	}
+	return strings.Join(append(append([]string{}, c.chain...), target), " -> ")
+}
+
+type state struct {
+	constraints map[string][]constraint
+	selected    map[string]*Release
+	// library keys in the order they were first required
+	keys []string
+}
+
+func (s *state) addConstraint(key string, c constraint) {
+	if _, ok := s.constraints[key]; !ok {
+		s.keys = append(s.keys, key)
+	}
+	s.constraints[key] = append(s.constraints[key], c)
+}
+
+func (s *state) removeLastConstraint(key string) {
+	s.constraints[key] = s.constraints[key][:len(s.constraints[key])-1]
+}
+
+func libraryKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// Resolve returns the chosen version of every needed library, starting with the project requirements
+func (r *Resolver) Resolve(requirements []Requirement) ([]Resolved, error) {
+	r.releases = make(map[string][]*Release)
+	r.steps = 0
+	r.conflict = nil
+	r.err = nil
+
+	s := &state{
+		constraints: make(map[string][]constraint),
+		selected:    make(map[string]*Release),
+	}
+	for _, requirement := range requirements {
+		s.addConstraint(libraryKey(requirement.Library), constraint{
+			name:  requirement.Library,
+			spec:  requirement.Version,
+			chain: []string{r.Root},
+		})
+	}
+
+	if !r.solve(s) {
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.conflict != nil {
+			return nil, r.conflict
+		}
+		return nil, errors.New("failed to resolve dependencies")
+	}
+
+	// collect the result starting from the project requirements
+	var result []Resolved
+	visited := make(map[string]bool)
+	pending := append([]string{}, s.keys...)
+	for len(pending) > 0 {
+		key := pending[0]
+		pending = pending[1:]
+		release, ok := s.selected[key]
+		if !ok || visited[key] {
+			continue
+		}
+		visited[key] = true
+		resolved := Resolved{Name: release.Name, Version: release.Version}
+		for _, dep := range release.Dependencies {
+			depKey := libraryKey(dep.Name)
+			if depRelease, ok := s.selected[depKey]; ok {
+				resolved.Dependencies = append(resolved.Dependencies, depRelease.Name)
+			}
+			pending = append(pending, depKey)
+		}
+		result = append(result, resolved)
+	}
+	return result, nil
+}
+
+func (r *Resolver) solve(s *state) bool {
+	r.steps++
+	if r.steps > maxSteps {
+		r.err = errors.New("failed to resolve dependencies: too many possible combinations")
+		return false
+	}
+
+	// next library to choose a version for
+	key := ""
+	for _, k := range s.keys {
+		if _, ok := s.selected[k]; !ok && len(s.constraints[k]) > 0 {
+			key = k
+			break
+		}
+	}
+	if key == "" {
+		return true
+	}
+	constraints := s.constraints[key]
+
+	candidates, err := r.candidates(key, constraints)
+	if err != nil {
+		r.err = err
+		return false
+	}
+	if len(candidates) == 0 {
+		r.recordConflict(key, constraints)
+		return false
+	}
+
+	for _, release := range candidates {
+		chain := append(append([]string{}, constraints[0].chain...), fmt.Sprintf("%s@%s", release.Name, release.Version))
+		var added []string
+		valid := true
+		for _, dep := range release.Dependencies {
+			depKey := libraryKey(dep.Name)
+			c := constraint{name: dep.Name, spec: dep.Constraint, arduino: true, chain: chain}
+			s.addConstraint(depKey, c)
+			added = append(added, depKey)
+			if selected, ok := s.selected[depKey]; ok && !c.matches(selected.Version, r.releases[depKey]) {
+				r.recordConflict(depKey, s.constraints[depKey])
+				valid = false
+				break
+			}
+		}
+
+		if valid {
+			s.selected[key] = release
+			if r.solve(s) {
+				return true
+			}
+			delete(s.selected, key)
+		}
+
+		for i := len(added) - 1; i >= 0; i-- {
+			s.removeLastConstraint(added[i])
+		}
+		if r.err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// candidates returns the releases matching every constraint, the preferred one first then the newest ones
+func (r *Resolver) candidates(key string, constraints []constraint) ([]*Release, error) {
+	releases, ok := r.releases[key]
+	if !ok {
+		var err error
+		releases, err = r.Index(constraints[0].name)
+		if err != nil {
+			return nil, err
+		}
+		if releases == nil {
+			return nil, errors.New(fmt.Sprintf("library '%s' not found in library index (%s)", constraints[0].name, constraints[0]))
+		}
+		r.releases[key] = releases
+	}
+
+	var result []*Release
+	for _, release := range releases {
+		matches := true
+		for _, c := range constraints {
+			if !c.matches(release.Version, releases) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			result = append(result, release)
+		}
+	}
+
+	preferred := ""
+	for name, version := range r.Preferred {
+		if libraryKey(name) == key {
+			preferred = version
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Version == preferred || result[j].Version == preferred {
+			return result[i].Version == preferred
+		}
+		return project.CompareVersions(result[i].Version, result[j].Version) > 0
+	})
+	return result, nil
+}
+
+// recordConflict keeps the first found conflict as it is the one of the newest versions
+func (r *Resolver) recordConflict(key string, constraints []constraint) {
+	if r.conflict != nil {
+		return
+	}
+	releases := r.releases[key]
+	conflict := &ConflictError{Library: key}
+	if len(constraints) > 0 {
+		conflict.Library = constraints[0].name
+	}
+
+	// look for a constraint which cannot be satisfied at all
+	for _, c := range constraints {
+		if !anyMatches(releases, c) {
+			conflict.Constraints = []string{c.String()}
+			r.conflict = conflict
+			return
+		}
+	}
+
+	// look for the two constraints which cannot be satisfied together
+	for i := 0; i < len(constraints); i++ {
+		for j := i + 1; j < len(constraints); j++ {
+			if !anyMatches(releases, constraints[i], constraints[j]) {
+				conflict.Constraints = []string{constraints[i].String(), constraints[j].String()}
+				r.conflict = conflict
+				return
+			}
+		}
+	}
+	for _, c := range constraints {
+		conflict.Constraints = append(conflict.Constraints, c.String())
+	}
+	if len(conflict.Constraints) > 1 {
+		conflict.Constraints = []string{strings.Join(conflict.Constraints, " and ")}
+	}
+	r.conflict = conflict
+}
+
+func anyMatches(releases []*Release, constraints ...constraint) bool {
+	for _, release := range releases {
+		matches := true
+		for _, c := range constraints {
+			if !c.matches(release.Version, releases) {
+				matches = false
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
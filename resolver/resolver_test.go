@@ -0,0 +1,176 @@
+package resolver
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testIndex returns an index of releases given as library name -> version -> dependency constraints
+func testIndex(libraries map[string]map[string][]Dependency) Index {
+	return func(name string) ([]*Release, error) {
+		versions, ok := libraries[name]
+		if !ok {
+			return nil, nil
+		}
+		var result []*Release
+		for version, dependencies := range versions {
+			result = append(result, &Release{Name: name, Version: version, Dependencies: dependencies})
+		}
+		return result, nil
+	}
+}
+
+func resolvedVersions(resolved []Resolved) map[string]string {
+	result := make(map[string]string)
+	for _, r := range resolved {
+		result[r.Name] = r.Version
+	}
+	return result
+}
+
+func TestResolve(t *testing.T) {
+	index := testIndex(map[string]map[string][]Dependency{
+		"A": {
+			"1.0.0": {{Name: "B", Constraint: ">=1.0.0"}},
+			"2.0.0": {{Name: "B", Constraint: ">=2.0.0"}},
+		},
+		"B": {
+			"1.0.0": nil,
+			"1.1.0": nil,
+			"2.0.0": nil,
+		},
+		"Beta": {
+			"0.1.0-beta": nil,
+			"0.2.0-beta": nil,
+		},
+	})
+	tests := []struct {
+		name         string
+		requirements []Requirement
+		preferred    map[string]string
+		expected     map[string]string
+	}{
+		{
+			name:         "newest versions",
+			requirements: []Requirement{{Library: "A", Version: "latest"}},
+			expected:     map[string]string{"A": "2.0.0", "B": "2.0.0"},
+		},
+		{
+			name:         "backtracking",
+			requirements: []Requirement{{Library: "A", Version: "latest"}, {Library: "B", Version: "^1.0.0"}},
+			expected:     map[string]string{"A": "1.0.0", "B": "1.1.0"},
+		},
+		{
+			name:         "preferred version",
+			requirements: []Requirement{{Library: "A", Version: "latest"}},
+			preferred:    map[string]string{"a": "1.0.0", "B": "1.0.0"},
+			expected:     map[string]string{"A": "1.0.0", "B": "1.0.0"},
+		},
+		{
+			name:         "preferred version which does not match",
+			requirements: []Requirement{{Library: "A", Version: "^2.0.0"}},
+			preferred:    map[string]string{"A": "1.0.0", "B": "1.0.0"},
+			expected:     map[string]string{"A": "2.0.0", "B": "2.0.0"},
+		},
+		{
+			name:         "latest pre-release",
+			requirements: []Requirement{{Library: "Beta", Version: "latest"}},
+			expected:     map[string]string{"Beta": "0.2.0-beta"},
+		},
+	}
+	for _, test := range tests {
+		r := &Resolver{Index: index, Root: "project", Preferred: test.preferred}
+		resolved, err := r.Resolve(test.requirements)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if actual := resolvedVersions(resolved); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: resolved %v, expected %v", test.name, actual, test.expected)
+		}
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	index := testIndex(map[string]map[string][]Dependency{
+		"A": {"1.0.0": {{Name: "B", Constraint: ""}}},
+		"B": {"1.0.0": nil},
+	})
+	r := &Resolver{Index: index, Root: "project"}
+	resolved, err := r.Resolve([]Requirement{{Library: "A", Version: "latest"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Resolved{
+		{Name: "A", Version: "1.0.0", Dependencies: []string{"B"}},
+		{Name: "B", Version: "1.0.0"},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("resolved %v, expected %v", resolved, expected)
+	}
+}
+
+func TestResolveConflict(t *testing.T) {
+	index := testIndex(map[string]map[string][]Dependency{
+		"A": {"1.0.0": {{Name: "C", Constraint: ">=2.0.0"}}},
+		"B": {"1.0.0": {{Name: "C", Constraint: "<2.0.0"}}},
+		"C": {"1.0.0": nil, "2.0.0": nil},
+	})
+	tests := []struct {
+		name         string
+		requirements []Requirement
+		expected     *ConflictError
+		message      string
+	}{
+		{
+			name:         "conflicting dependencies",
+			requirements: []Requirement{{Library: "A", Version: "^1.0.0"}, {Library: "B", Version: "^1.0.0"}},
+			expected: &ConflictError{Library: "C", Constraints: []string{
+				"project -> A@1.0.0 -> C >=2.0.0",
+				"project -> B@1.0.0 -> C <2.0.0",
+			}},
+			message: "dependency conflict on 'C': project -> A@1.0.0 -> C >=2.0.0 conflicts with project -> B@1.0.0 -> C <2.0.0",
+		},
+		{
+			name:         "no matching release",
+			requirements: []Requirement{{Library: "C", Version: "^5.0.0"}},
+			expected:     &ConflictError{Library: "C", Constraints: []string{"project -> C@^5.0.0"}},
+			message:      "dependency conflict on 'C': no release matches project -> C@^5.0.0",
+		},
+		{
+			name:         "conflict with the project",
+			requirements: []Requirement{{Library: "A", Version: "latest"}, {Library: "C", Version: "1.0.0"}},
+			expected: &ConflictError{Library: "C", Constraints: []string{
+				"project -> C@1.0.0",
+				"project -> A@1.0.0 -> C >=2.0.0",
+			}},
+			message: "dependency conflict on 'C': project -> C@1.0.0 conflicts with project -> A@1.0.0 -> C >=2.0.0",
+		},
+	}
+	for _, test := range tests {
+		r := &Resolver{Index: index, Root: "project"}
+		_, err := r.Resolve(test.requirements)
+		conflict, ok := err.(*ConflictError)
+		if !ok {
+			t.Errorf("%s: expected a conflict, got %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(conflict, test.expected) {
+			t.Errorf("%s: conflict %#v, expected %#v", test.name, conflict, test.expected)
+		}
+		if conflict.Error() != test.message {
+			t.Errorf("%s: message %q, expected %q", test.name, conflict.Error(), test.message)
+		}
+	}
+}
+
+func TestResolveUnknownLibrary(t *testing.T) {
+	r := &Resolver{Index: testIndex(nil), Root: "project"}
+	_, err := r.Resolve([]Requirement{{Library: "Missing", Version: "latest"}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, ok := err.(*ConflictError); ok {
+		t.Errorf("expected a not found error, got %s", err)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	rpc "github.com/arduino/arduino-cli/rpc/cc/arduino/cli/commands/v1"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/util"
+)
+
+// SelectBoard lets the user search for a board in the installed and installable core packages,
+// the board ID is only known (and set) for boards of installed core packages
+func SelectBoard(cli *arduino.ArduinoCli) (*project.ProjectBoard, error) {
+	selected, err := util.AutoCompleteInput("Board search", "Search again...", "Cancel",
+		func(query string) (map[string]interface{}, error) {
+			result := make(map[string]interface{})
+			boards, err := cli.SearchBoards(query)
+			if err != nil {
+				return nil, err
+			}
+			for _, board := range boards {
+				boardTitle := fmt.Sprintf("%s - %s (%s)", board.Name, board.Platform.Id, board.Platform.Name)
+				if board.Fqbn != "" {
+					boardTitle = fmt.Sprintf("%s - %s (%s)", board.Name, board.Fqbn, board.Platform.Name)
+				}
+				result[boardTitle] = board
+			}
+			return result, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	item := selected.(*rpc.BoardListItem)
+	board, err := BoardFromFqbn(item.Platform.Id)
+	if err != nil {
+		return nil, err
+	}
+	if item.Fqbn != "" {
+		board, err = BoardFromFqbn(item.Fqbn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return board, nil
+}
+
+// SelectBoardVersion lets the user select the version of a board core package, the newest versions come first
+func SelectBoardVersion(cli *arduino.ArduinoCli, board *project.ProjectBoard) (string, error) {
+	versions, err := cli.PlatformVersions(board.Package, board.Architecture)
+	if err != nil {
+		return "", err
+	}
+	project.SortVersions(versions)
+	items := []string{"latest"}
+	for i := len(versions) - 1; i >= 0; i-- {
+		items = append(items, versions[i])
+	}
+	selected, err := util.Select("Select core version", items, nil)
+	if err != nil {
+		return "", err
+	}
+	return selected.(string), nil
+}
+
+// BoardFromFqbn returns the board of a FQBN (PACKAGE:ARCHITECTURE or PACKAGE:ARCHITECTURE:BOARD)
+func BoardFromFqbn(fqbn string) (*project.ProjectBoard, error) {
+	parts := strings.Split(fqbn, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, errors.New(fmt.Sprintf("invalid board '%s', please use PACKAGE:ARCHITECTURE or PACKAGE:ARCHITECTURE:BOARD", fqbn))
+	}
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			return nil, errors.New(fmt.Sprintf("invalid board '%s', please use PACKAGE:ARCHITECTURE or PACKAGE:ARCHITECTURE:BOARD", fqbn))
+		}
+	}
+	board := &project.ProjectBoard{Package: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		board.Board = parts[2]
+	}
+	return board, nil
+}
+
+// CheckIfBoardValid checks that the board core package exists in the (already updated) package indexes
+// and that one of its releases matches the version of the board
+func CheckIfBoardValid(cli *arduino.ArduinoCli, board *project.ProjectBoard) error {
+	_, err := project.ParseVersionSpec(board.Version)
+	if err != nil {
+		return err
+	}
+	versions, err := cli.PlatformVersions(board.Package, board.Architecture)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		message := fmt.Sprintf("unknown board core package '%s:%s'", board.Package, board.Architecture)
+		if len(board.IndexUrls()) == 0 {
+			message += ", the board manager URL may be missing"
+		}
+		return errors.New(message)
+	}
+	if !project.IsLatest(board.Version) && project.MaxSatisfying(board.Version, versions) == "" {
+		project.SortVersions(versions)
+		return errors.New(fmt.Sprintf("no release of board core '%s:%s' matches version '%s', available versions: %s",
+			board.Package, board.Architecture, board.Version, strings.Join(versions, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"sort"
+	"strings"
+)
+
+var GraphFormats = []string{"text", "dot", "mermaid", "json"}
+
+// DependencyGraph is the graph of the libraries needed by the project with their resolved versions
+type DependencyGraph struct {
+	// name of the project
+	Root string
+	// libraries declared in apm.json
+	Declared []string
+	// resolved versions by library name
+	Versions map[string]string
+	// names of the libraries needed by a library
+	Edges map[string][]string
+}
+
+// GraphNode is a library in the dependency tree
+type GraphNode struct {
+	Name         string       `json:"name"`
+	Version      string       `json:"version,omitempty"`
+	Dependencies []*GraphNode `json:"dependencies,omitempty"`
+}
+
+// GetDependencyGraph builds the dependency graph from the lock file if it still matches the project,
+// otherwise the dependencies are resolved from the library index
+func GetDependencyGraph(cli *arduino.ArduinoCli, details *project.ProjectDetails, lock *project.ProjectLock) (*DependencyGraph, error) {
+	graph := &DependencyGraph{
+		Root:     details.Name(),
+		Versions: make(map[string]string),
+		Edges:    make(map[string][]string),
+	}
+
+	if lockedDeps, ok := lock.LockedDependencies(details); ok {
+		for _, locked := range lockedDeps {
+			if locked.Library != "" {
+				graph.Versions[locked.Library] = locked.Version
+				graph.Edges[locked.Library] = locked.Dependencies
+			}
+		}
+	} else {
+		err := cli.UpdateLibraryIndex()
+		if err != nil {
+			return nil, err
+		}
+		resolved, err := cli.ResolveDependencies(details, lock)
+		if err != nil {
+			return nil, err
+		}
+		for _, lib := range resolved {
+			graph.Versions[lib.Name] = lib.Version
+			graph.Edges[lib.Name] = lib.Dependencies
+		}
+	}
+
+	// declared libraries, git and zip dependencies
+	for _, dep := range details.Dependencies {
+		if dep.Library != "" {
+			graph.Declared = append(graph.Declared, graph.libraryName(dep.Library))
+			continue
+		}
+		name := dep.Git
+		if name == "" {
+			name = dep.Zip
+		}
+		if locked := lock.Locked(dep); locked != nil {
+			graph.Versions[name] = locked.Commit
+		}
+		graph.Declared = append(graph.Declared, name)
+	}
+	return graph, nil
+}
+
+// libraryName returns the name of the library as it is in the index
+func (g *DependencyGraph) libraryName(name string) string {
+	for libName := range g.Versions {
+		if strings.ToLower(libName) == strings.ToLower(name) {
+			return libName
+		}
+	}
+	return name
+}
+
+// label returns the name of the library with its version
+func (g *DependencyGraph) label(name string) string {
+	if name == g.Root || g.Versions[name] == "" {
+		return name
+	}
+	return fmt.Sprintf("%s@%s", name, g.Versions[name])
+}
+
+// children returns the libraries directly needed by the project (root) or a library
+func (g *DependencyGraph) children(name string) []string {
+	if name == g.Root {
+		return g.Declared
+	}
+	var result []string
+	for _, dep := range g.Edges[name] {
+		result = append(result, g.libraryName(dep))
+	}
+	return result
+}
+
+// Tree returns the dependency tree of the project, libraries already on the current path are not repeated
+func (g *DependencyGraph) Tree() *GraphNode {
+	return g.treeNode(g.Root, map[string]bool{})
+}
+
+func (g *DependencyGraph) treeNode(name string, path map[string]bool) *GraphNode {
+	node := &GraphNode{Name: name}
+	if name != g.Root {
+		node.Version = g.Versions[name]
+	}
+	if path[name] {
+		return node
+	}
+	path[name] = true
+	for _, child := range g.children(name) {
+		node.Dependencies = append(node.Dependencies, g.treeNode(child, path))
+	}
+	delete(path, name)
+	return node
+}
+
+// Why returns every path from the project to the given library
+func (g *DependencyGraph) Why(library string) ([][]string, error) {
+	target := g.libraryName(library)
+	var result [][]string
+	var walk func(path []string)
+	walk = func(path []string) {
+		current := path[len(path)-1]
+		for _, child := range g.children(current) {
+			if containsName(path, child) {
+				continue
+			}
+			childPath := append(append([]string{}, path...), child)
+			if child == target {
+				result = append(result, childPath)
+				continue
+			}
+			walk(childPath)
+		}
+	}
+	walk([]string{g.Root})
+	if len(result) == 0 {
+		return nil, errors.New(fmt.Sprintf("'%s' is not a dependency of the project", library))
+	}
+	return result, nil
+}
+
+// Subgraph returns a graph which contains only the given paths
+func (g *DependencyGraph) Subgraph(paths [][]string) *DependencyGraph {
+	result := &DependencyGraph{
+		Root:     g.Root,
+		Versions: g.Versions,
+		Edges:    make(map[string][]string),
+	}
+	for _, path := range paths {
+		for i := 1; i < len(path); i++ {
+			if path[i-1] == g.Root {
+				if !containsName(result.Declared, path[i]) {
+					result.Declared = append(result.Declared, path[i])
+				}
+			} else if !containsName(result.Edges[path[i-1]], path[i]) {
+				result.Edges[path[i-1]] = append(result.Edges[path[i-1]], path[i])
+			}
+		}
+	}
+	return result
+}
+
+// FormatTree renders the dependency tree in the given format
+func (g *DependencyGraph) FormatTree(format string) (string, error) {
+	switch format {
+	case "text":
+		var builder strings.Builder
+		builder.WriteString(g.Root + "\n")
+		g.writeTreeText(&builder, g.Tree(), "")
+		return builder.String(), nil
+	case "json":
+		data, err := json.MarshalIndent(g.Tree(), "", "    ")
+		return string(data) + "\n", err
+	}
+	return g.formatGraph(format)
+}
+
+func (g *DependencyGraph) writeTreeText(builder *strings.Builder, node *GraphNode, indent string) {
+	for i, child := range node.Dependencies {
+		branch, childIndent := "├── ", "│   "
+		if i == len(node.Dependencies)-1 {
+			branch, childIndent = "└── ", "    "
+		}
+		builder.WriteString(indent + branch + g.label(child.Name) + "\n")
+		g.writeTreeText(builder, child, indent+childIndent)
+	}
+}
+
+// FormatPaths renders paths returned by Why in the given format
+func (g *DependencyGraph) FormatPaths(paths [][]string, format string) (string, error) {
+	switch format {
+	case "text":
+		var builder strings.Builder
+		for _, path := range paths {
+			var labels []string
+			for _, name := range path {
+				labels = append(labels, g.label(name))
+			}
+			builder.WriteString(strings.Join(labels, " -> ") + "\n")
+		}
+		return builder.String(), nil
+	case "json":
+		var result [][]*GraphNode
+		for _, path := range paths {
+			var nodes []*GraphNode
+			for _, name := range path {
+				node := &GraphNode{Name: name}
+				if name != g.Root {
+					node.Version = g.Versions[name]
+				}
+				nodes = append(nodes, node)
+			}
+			result = append(result, nodes)
+		}
+		data, err := json.MarshalIndent(result, "", "    ")
+		return string(data) + "\n", err
+	}
+	return g.Subgraph(paths).formatGraph(format)
+}
+
+// formatGraph renders every edge of the graph in dot or mermaid format
+func (g *DependencyGraph) formatGraph(format string) (string, error) {
+	// collect nodes in a stable order
+	nodes := []string{g.Root}
+	nodes = append(nodes, g.Declared...)
+	var libs []string
+	for name := range g.Edges {
+		libs = append(libs, name)
+	}
+	sort.Strings(libs)
+	for _, name := range libs {
+		if !containsName(nodes, name) {
+			nodes = append(nodes, name)
+		}
+		for _, dep := range g.children(name) {
+			if !containsName(nodes, dep) {
+				nodes = append(nodes, dep)
+			}
+		}
+	}
+	ids := make(map[string]string)
+	for i, name := range nodes {
+		ids[name] = fmt.Sprintf("n%d", i)
+	}
+
+	var builder strings.Builder
+	switch format {
+	case "dot":
+		builder.WriteString("digraph dependencies {\n")
+		for _, name := range nodes {
+			builder.WriteString(fmt.Sprintf("    %s [label=%q];\n", ids[name], g.label(name)))
+		}
+		for _, name := range nodes {
+			for _, dep := range g.children(name) {
+				builder.WriteString(fmt.Sprintf("    %s -> %s;\n", ids[name], ids[dep]))
+			}
+		}
+		builder.WriteString("}\n")
+	case "mermaid":
+		builder.WriteString("graph TD\n")
+		for _, name := range nodes {
+			builder.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", ids[name], strings.ReplaceAll(g.label(name), "\"", "#quot;")))
+		}
+		for _, name := range nodes {
+			for _, dep := range g.children(name) {
+				builder.WriteString(fmt.Sprintf("    %s --> %s\n", ids[name], ids[dep]))
+			}
+		}
+	default:
+		return "", errors.New(fmt.Sprintf("unknown format '%s', please use one of: %s", format, strings.Join(GraphFormats, ", ")))
+	}
+	return builder.String(), nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
 	"github.com/ksrichard/apm/util"
 	"strings"
 )
@@ -54,6 +55,11 @@ func SelectLibrary(cli *arduino.ArduinoCli) (string, string, error) {
 }
 
 func CheckIfLibraryValid(cli *arduino.ArduinoCli, libName string, libVersion string, maxHints int) (string, error) {
+	_, err := project.ParseVersionSpec(libVersion)
+	if err != nil {
+		return "", err
+	}
+
 	libs, err := cli.SearchLibrary(libName)
 	if err != nil {
 		return "", err
@@ -65,7 +71,7 @@ func CheckIfLibraryValid(cli *arduino.ArduinoCli, libName string, libVersion str
 	finalLibName := libName
 	foundLibName := false
 	foundLibVersion := false
-	if strings.ToLower(libVersion) == "latest" {
+	if project.IsLatest(libVersion) {
 		foundLibVersion = true
 	}
 	for _, lib := range libs {
@@ -74,7 +80,7 @@ func CheckIfLibraryValid(cli *arduino.ArduinoCli, libName string, libVersion str
 			finalLibName = lib.Name
 			for _, release := range lib.Releases {
 				libAllVersions = append(libAllVersions, release.Version)
-				if release.Version == libVersion {
+				if project.VersionSatisfies(libVersion, release.Version) {
 					foundLibVersion = true
 				}
 			}
@@ -96,9 +102,10 @@ func CheckIfLibraryValid(cli *arduino.ArduinoCli, libName string, libVersion str
 	}
 
 	if !foundLibVersion {
-		fmt.Printf("Unknown library version '%s'!\n", libVersion)
+		project.SortVersions(libAllVersions[1:])
+		fmt.Printf("No library version matches '%s'!\n", libVersion)
 		fmt.Printf("You can use the following versions:\n %s", strings.Join(libAllVersions, "\n"))
-		return "", errors.New(fmt.Sprintf("Unknown library version '%s'!", libVersion))
+		return "", errors.New(fmt.Sprintf("No library version matches '%s'!", libVersion))
 	}
 
 	return finalLibName, nil
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"fmt"
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"strings"
+)
+
+type DependencyVersions struct {
+	// library name or package:architecture of the board core
+	Name string
+	// version set in apm.json
+	Spec      string
+	Installed string
+	// newest version allowed by the spec
+	Wanted string
+	Latest string
+	Board  bool
+	// git dependency, Installed is the locked commit and Latest is the newest tag
+	Git bool
+	// tags of a git dependency newer than its ref
+	NewerTags []string
+}
+
+// IsOutdated returns true if the dependency is not installed or a newer release is available
+func (d DependencyVersions) IsOutdated() bool {
+	if d.Git {
+		return d.Installed == "" || len(d.NewerTags) > 0
+	}
+	return d.Installed == "" || (d.Latest != "" && project.CompareVersions(d.Installed, d.Latest) < 0)
+}
+
+// CanUpdate returns true if a newer version is available which is still allowed by the spec
+func (d DependencyVersions) CanUpdate() bool {
+	return d.Wanted != "" && (d.Installed == "" || project.CompareVersions(d.Installed, d.Wanted) < 0)
+}
+
+// GetDependencyVersions collects the declared, installed and available versions of the board core and all libraries
+// of the project and the tags of git dependencies. The indexes must be up to date.
+func GetDependencyVersions(cli *arduino.ArduinoCli, details *project.ProjectDetails, lock *project.ProjectLock) ([]DependencyVersions, error) {
+	var result []DependencyVersions
+
+	// board core
+	if details.Board != nil && details.Board.Package != "" {
+		board := details.Board
+		installed, err := cli.InstalledPlatformVersion(board.Package, board.Architecture)
+		if err != nil {
+			return nil, err
+		}
+		versions, err := cli.PlatformVersions(board.Package, board.Architecture)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, DependencyVersions{
+			Name:      fmt.Sprintf("%s:%s", board.Package, board.Architecture),
+			Spec:      board.Version,
+			Installed: installed,
+			Wanted:    project.MaxSatisfying(board.Version, versions),
+			Latest:    project.MaxSatisfying("latest", versions),
+			Board:     true,
+		})
+	}
+
+	// libraries
+	installedLibs, err := cli.InstalledLibraries()
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range details.Dependencies {
+		if dep.Library == "" {
+			continue
+		}
+		lib, err := cli.FindLibrary(dep.Library)
+		if err != nil {
+			return nil, err
+		}
+		var versions []string
+		for version := range lib.Releases {
+			versions = append(versions, version)
+		}
+		latest := project.MaxSatisfying("latest", versions)
+		if lib.Latest != nil {
+			latest = lib.Latest.Version
+		}
+		wanted := project.MaxSatisfying(dep.Version, versions)
+		if project.IsLatest(dep.Version) {
+			wanted = latest
+		}
+		result = append(result, DependencyVersions{
+			Name:      lib.Name,
+			Spec:      dep.Version,
+			Installed: installedLibs[strings.ToLower(lib.Name)],
+			Wanted:    wanted,
+			Latest:    latest,
+		})
+	}
+
+	// git dependencies
+	for _, dep := range details.Dependencies {
+		if dep.Git == "" {
+			continue
+		}
+		gitVersions, err := getGitVersions(cli, dep, lock)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *gitVersions)
+	}
+
+	return result, nil
+}
+
+// getGitVersions collects the locked commit and the tags of a git dependency
+func getGitVersions(cli *arduino.ArduinoCli, dep project.ProjectDependency, lock *project.ProjectLock) (*DependencyVersions, error) {
+	result := &DependencyVersions{
+		Name: dep.Git,
+		Spec: dep.Ref,
+		Git:  true,
+	}
+	if result.Spec == "" {
+		result.Spec = "HEAD"
+	}
+	if locked := lock.Locked(dep); locked != nil {
+		result.Installed = locked.Commit
+	}
+
+	tags, err := cli.GitTags(dep.Git)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for tag := range tags {
+		versions = append(versions, tag)
+	}
+	result.Latest = project.MaxSatisfying("latest", versions)
+
+	// tags are newer than the ref if it is a tag or the locked commit is tagged
+	current := ""
+	if _, ok := tags[dep.Ref]; ok {
+		current = dep.Ref
+	}
+	for tag, commit := range tags {
+		if current == "" && result.Installed != "" && commit == result.Installed && project.VersionSatisfies("latest", tag) {
+			current = tag
+		}
+	}
+	if current != "" {
+		for _, version := range versions {
+			if project.VersionSatisfies("latest", version) && project.CompareVersions(version, current) > 0 {
+				result.NewerTags = append(result.NewerTags, version)
+			}
+		}
+		project.SortVersions(result.NewerTags)
+	}
+	return result, nil
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ksrichard/apm/arduino"
+	"github.com/ksrichard/apm/project"
+	"github.com/ksrichard/apm/util"
+)
+
+var includeRegex = regexp.MustCompile(`^\s*#\s*include\s*[<"]([^>"]+)[>"]`)
+
+// sketchExtensions are the extensions of the files scanned for #include directives
+var sketchExtensions = map[string]bool{".ino": true, ".pde": true, ".c": true, ".cpp": true, ".cc": true, ".h": true, ".hpp": true, ".hh": true}
+
+// standardHeaders are the headers of the C and C++ standard libraries of the toolchains
+var standardHeaders = map[string]bool{}
+
+func init() {
+	for _, header := range strings.Fields(`assert.h ctype.h errno.h float.h inttypes.h limits.h locale.h math.h setjmp.h
+		signal.h stdarg.h stdbool.h stddef.h stdint.h stdio.h stdlib.h string.h time.h wchar.h
+		algorithm array atomic bitset cassert cctype cmath cstddef cstdint cstdio cstdlib cstring deque functional
+		initializer_list iterator limits list map memory mutex new numeric queue set sstream stack string
+		thread tuple type_traits unordered_map unordered_set utility vector`) {
+		standardHeaders[header] = true
+	}
+}
+
+// Include is an #include directive of a sketch
+type Include struct {
+	Header string
+	File   string
+	Line   int
+}
+
+// ScannedLibrary is a library used by the sketch with the headers it provides
+type ScannedLibrary struct {
+	Name    string
+	Version string
+	// the library is installed in the sketchbook (Version is the installed version)
+	Installed bool
+	Headers   []string
+}
+
+// ScanResult contains the libraries used by a sketch and the headers which do not belong to a library
+type ScanResult struct {
+	Libraries []ScannedLibrary
+	// headers of the board core package and of the toolchain
+	CoreHeaders []string
+	// headers which are not provided by any known library
+	UnknownHeaders []string
+}
+
+// ScanIncludes returns the #include directives of the sketch files in the project directory, the headers of the sketch
+// itself are skipped. Hidden directories and the given directories (e.g. the vendor directory) are not scanned.
+func ScanIncludes(dir string, skipDirs []string) ([]Include, error) {
+	skip := make(map[string]bool)
+	for _, skipDir := range skipDirs {
+		if skipDir != "" {
+			skip[filepath.Clean(skipDir)] = true
+		}
+	}
+	var includes []Include
+	localHeaders := make(map[string]bool)
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if filePath != dir && (strings.HasPrefix(info.Name(), ".") || skip[filepath.Clean(filePath)]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !sketchExtensions[strings.ToLower(filepath.Ext(filePath))] {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		localHeaders[strings.ToLower(filepath.ToSlash(relPath))] = true
+		localHeaders[strings.ToLower(info.Name())] = true
+		fileIncludes, err := fileIncludes(filePath)
+		if err != nil {
+			return err
+		}
+		includes = append(includes, fileIncludes...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []Include
+	for _, include := range includes {
+		header := strings.ToLower(include.Header)
+		if !localHeaders[header] && !localHeaders[path.Base(header)] {
+			result = append(result, include)
+		}
+	}
+	return result, nil
+}
+
+// fileIncludes returns the #include directives of a file, commented out directives are skipped
+func fileIncludes(filePath string) ([]Include, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var result []Include
+	scanner := bufio.NewScanner(file)
+	inComment := false
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var line string
+		line, inComment = stripComments(scanner.Text(), inComment)
+		if match := includeRegex.FindStringSubmatch(line); match != nil {
+			result = append(result, Include{Header: strings.TrimSpace(match[1]), File: filePath, Line: lineNumber})
+		}
+	}
+	return result, scanner.Err()
+}
+
+// stripComments removes the comments of a line, inComment is true if the line starts in a block comment
+func stripComments(line string, inComment bool) (string, bool) {
+	var result strings.Builder
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inComment:
+			if strings.HasPrefix(line[i:], "*/") {
+				inComment = false
+				i++
+			}
+		case quote != 0:
+			result.WriteByte(c)
+			if c == '\\' && i+1 < len(line) {
+				i++
+				result.WriteByte(line[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case strings.HasPrefix(line[i:], "//"):
+			return result.String(), false
+		case strings.HasPrefix(line[i:], "/*"):
+			inComment = true
+			i++
+		default:
+			if c == '"' || c == '\'' {
+				quote = c
+			}
+			result.WriteByte(c)
+		}
+	}
+	return result.String(), inComment
+}
+
+// ResolveIncludes maps the headers of a sketch to libraries using the installed libraries and the provides_includes
+// of the library index. Headers of the board core package of the project are skipped. If more than one library provides
+// a header, the user selects one of them. Installed libraries are used with their installed versions.
+func ResolveIncludes(cli *arduino.ArduinoCli, details *project.ProjectDetails, includes []Include) (*ScanResult, error) {
+	coreHeaders := make(map[string]bool)
+	if details.Board != nil && details.Board.Package != "" && details.Board.Architecture != "" {
+		headers, err := cli.PlatformIncludes(details.Board.Package, details.Board.Architecture)
+		if err != nil {
+			log.Printf("WARNING: %s, headers of the board core can not be recognized\n", err)
+		}
+		coreHeaders = headers
+	} else {
+		log.Println("WARNING: no board is set, headers of the board core can not be recognized")
+	}
+	installedIncludes, err := cli.InstalledLibraryIncludes()
+	if err != nil {
+		return nil, err
+	}
+	indexIncludes, err := cli.IndexLibraryIncludes()
+	if err != nil {
+		return nil, err
+	}
+	installedVersions, err := cli.InstalledLibraries()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScanResult{}
+	seen := make(map[string]bool)
+	for _, include := range includes {
+		key := strings.ToLower(include.Header)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if coreHeaders[path.Base(key)] || standardHeaders[key] {
+			result.CoreHeaders = append(result.CoreHeaders, include.Header)
+			continue
+		}
+
+		// libraries already used by the sketch are preferred, then the installed ones
+		candidates := installedIncludes[key]
+		if len(candidates) == 0 {
+			candidates = indexIncludes[key]
+		}
+		if len(candidates) == 0 {
+			result.UnknownHeaders = append(result.UnknownHeaders, include.Header)
+			continue
+		}
+		name := ""
+		for _, lib := range result.Libraries {
+			if containsIgnoreCase(candidates, lib.Name) {
+				name = lib.Name
+			}
+		}
+		if name == "" && len(candidates) == 1 {
+			name = candidates[0]
+		}
+		if name == "" {
+			selected, err := util.Select(fmt.Sprintf("More than one library provides '%s' (%s:%d), select one", include.Header, include.File, include.Line),
+				append(append([]string{}, candidates...), "Skip"), nil)
+			if err != nil {
+				return nil, err
+			}
+			if selected == "Skip" {
+				result.UnknownHeaders = append(result.UnknownHeaders, include.Header)
+				continue
+			}
+			name = selected.(string)
+		}
+		result.addLibrary(name, include.Header, installedVersions)
+	}
+	return result, nil
+}
+
+func (r *ScanResult) addLibrary(name string, header string, installedVersions map[string]string) {
+	for i, lib := range r.Libraries {
+		if strings.ToLower(lib.Name) == strings.ToLower(name) {
+			r.Libraries[i].Headers = append(r.Libraries[i].Headers, header)
+			return
+		}
+	}
+	lib := ScannedLibrary{Name: name, Version: "latest", Headers: []string{header}}
+	if version, ok := installedVersions[strings.ToLower(name)]; ok && version != "" {
+		lib.Version = version
+		lib.Installed = true
+	}
+	r.Libraries = append(r.Libraries, lib)
+}
+
+func containsIgnoreCase(items []string, item string) bool {
+	for _, i := range items {
+		if strings.ToLower(i) == strings.ToLower(item) {
+			return true
+		}
+	}
+	return false
+}
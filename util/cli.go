@@ -69,7 +69,7 @@ func AutoCompleteInput(title string, searchAgainStr string, cancelStr string, re
 			continue
 		}
 		if res != nil && len(res) == 0 {
-			fmt.Printf("No library found for search query '%s'!\n", query)
+			fmt.Printf("Nothing found for search query '%s'!\n", query)
 		}
 		for k, v := range res {
 			items[k] = v
@@ -89,3 +89,65 @@ func AutoCompleteInput(title string, searchAgainStr string, cancelStr string, re
 
 	return selectedOption, nil
 }
+
+// MultiSelect lets the user toggle items on/off until "Apply" is selected, the returned slice contains the final states
+func MultiSelect(label string, items []string, selected []bool) ([]bool, error) {
+	applyStr := "Apply"
+	cancelStr := "Cancel"
+	result := append([]bool{}, selected...)
+	for {
+		options := []string{applyStr, cancelStr}
+		for i, item := range items {
+			mark := "[ ]"
+			if result[i] {
+				mark = "[x]"
+			}
+			options = append(options, fmt.Sprintf("%s %s", mark, item))
+		}
+
+		choice, err := Select(label, options, nil)
+		if err != nil {
+			return nil, err
+		}
+		switch choice {
+		case applyStr:
+			return result, nil
+		case cancelStr:
+			return nil, errors.New("cancelled")
+		}
+		for i := range items {
+			if choice == options[i+2] {
+				result[i] = !result[i]
+			}
+		}
+	}
+}
+
+// Input asks for a line of text, the default value is returned if nothing is entered
+func Input(label string, defaultValue string) (string, error) {
+	prompt := promptui.Prompt{
+		Label:   label,
+		Default: defaultValue,
+	}
+	result, err := prompt.Run()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result), nil
+}
+
+// Confirm asks a yes/no question
+func Confirm(label string) (bool, error) {
+	prompt := promptui.Prompt{
+		Label:     label,
+		IsConfirm: true,
+	}
+	_, err := prompt.Run()
+	if err == promptui.ErrAbort {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
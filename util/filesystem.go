@@ -1,6 +1,14 @@
 package util
 
-import "os"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
 
 func FileExists(filename string) bool {
 	info, err := os.Stat(filename)
@@ -9,3 +17,75 @@ func FileExists(filename string) bool {
 	}
 	return !info.IsDir()
 }
+
+func FileSha256(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// DirSha256 returns a hash of the relative paths and the contents of all files in the directory
+func DirSha256(dir string) (string, error) {
+	hash := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fileHash, err := FileSha256(path)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(hash, "%s %s\n", fileHash, filepath.ToSlash(relPath))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// WriteFileAtomic writes the file into a temporary file next to it and renames it,
+// so the file is either the previous or the new one even if apm is interrupted.
+// An existing file keeps its permissions, new files get perm without the executable bits.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	mode := perm &^ 0111
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, mode)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+	}
+	return err
+}